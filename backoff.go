@@ -0,0 +1,185 @@
+package utility
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy computes the delay between attempts of a retried operation.
+// Implementations must be safe for concurrent use unless they document
+// otherwise.
+type BackoffStrategy interface {
+	// Delay returns how long to wait before the next attempt, given the number
+	// of attempts that have failed so far (starting at 1), the delay that
+	// preceded the most recent attempt (0 if there was none), and the
+	// configured minimum and maximum delays. The result is clamped to
+	// [min, max] by the caller.
+	Delay(attempt int, previous, min, max time.Duration) time.Duration
+}
+
+// BackoffFunc is an adapter to allow the use of ordinary functions as a
+// BackoffStrategy.
+type BackoffFunc func(attempt int, previous, min, max time.Duration) time.Duration
+
+// Delay returns f(attempt, previous, min, max).
+func (f BackoffFunc) Delay(attempt int, previous, min, max time.Duration) time.Duration {
+	return f(attempt, previous, min, max)
+}
+
+// ConstantBackoff always waits for the minimum delay between attempts.
+type ConstantBackoff struct{}
+
+// Delay returns the minimum delay.
+func (ConstantBackoff) Delay(_ int, _, min, _ time.Duration) time.Duration { return min }
+
+// LinearBackoff increases the delay by a fixed increment after each attempt,
+// starting at the minimum delay.
+type LinearBackoff struct {
+	// Increment is the amount the delay grows by after each attempt. By
+	// default, it is the minimum delay.
+	Increment time.Duration
+}
+
+// Delay returns min + (attempt-1) * Increment.
+func (b LinearBackoff) Delay(attempt int, _, min, _ time.Duration) time.Duration {
+	increment := b.Increment
+	if increment <= 0 {
+		increment = min
+	}
+	return safeDuration(float64(min) + float64(attempt-1)*float64(increment))
+}
+
+// ExponentialBackoff multiplies the delay by a constant factor after each
+// attempt, starting at the minimum delay.
+type ExponentialBackoff struct {
+	// Factor is the multiplier applied after each attempt. By default, it is
+	// 2.
+	Factor float64
+	// Jitter randomizes each delay between the minimum delay and the
+	// exponential delay to avoid synchronized retries.
+	Jitter bool
+	// Rand, if set, is the source of randomness for jitter, which makes the
+	// delays reproducible when it is seeded. A *rand.Rand is not safe for
+	// concurrent use, so a strategy with Rand set should only be used by one
+	// operation at a time. By default, the global generator is used.
+	Rand *rand.Rand
+}
+
+// Delay returns min * Factor^(attempt-1), randomized if Jitter is set.
+func (b ExponentialBackoff) Delay(attempt int, _, min, _ time.Duration) time.Duration {
+	factor := b.Factor
+	if factor <= 0 {
+		factor = backoffFactor
+	}
+	minf := float64(min)
+	delay := minf * math.Pow(factor, float64(attempt-1))
+	if b.Jitter {
+		delay = randFloat64(b.Rand)*(delay-minf) + minf
+	}
+	return safeDuration(delay)
+}
+
+// DecorrelatedJitterBackoff picks each delay randomly between the minimum
+// delay and three times the previous delay. This spreads out retries from
+// many clients more evenly than exponential backoff with jitter.
+type DecorrelatedJitterBackoff struct {
+	// Rand, if set, is the source of randomness, which makes the delays
+	// reproducible when it is seeded. A *rand.Rand is not safe for concurrent
+	// use, so a strategy with Rand set should only be used by one operation
+	// at a time. By default, the global generator is used.
+	Rand *rand.Rand
+}
+
+// Delay returns a random duration in [min, 3*previous].
+func (b DecorrelatedJitterBackoff) Delay(_ int, previous, min, _ time.Duration) time.Duration {
+	if previous < min {
+		previous = min
+	}
+	minf := float64(min)
+	return safeDuration(minf + randFloat64(b.Rand)*(3*float64(previous)-minf))
+}
+
+// FibonacciBackoff grows the delay following the Fibonacci sequence (1, 1, 2,
+// 3, 5, ...) in multiples of the minimum delay.
+type FibonacciBackoff struct{}
+
+// Delay returns min * Fib(attempt).
+func (FibonacciBackoff) Delay(attempt int, _, min, _ time.Duration) time.Duration {
+	prev, cur := 0.0, 1.0
+	for i := 1; i < attempt && cur < math.MaxInt64; i++ {
+		prev, cur = cur, prev+cur
+	}
+	return safeDuration(float64(min) * cur)
+}
+
+// defaultBackoffStrategy is exponential backoff with a factor of 2 and jitter.
+var defaultBackoffStrategy BackoffStrategy = ExponentialBackoff{Factor: backoffFactor, Jitter: true}
+
+// randFloat64 returns a random number in [0.0, 1.0) from r, or from the
+// global generator if r is nil.
+func randFloat64(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
+
+// safeDuration converts d to a duration, saturating rather than overflowing.
+func safeDuration(d float64) time.Duration {
+	if d >= math.MaxInt64 || math.IsNaN(d) {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// backoff produces the sequence of delays for a single retried operation
+// according to its strategy.
+type backoff struct {
+	strategy BackoffStrategy
+	Min      time.Duration
+	Max      time.Duration
+
+	attempt  int
+	previous time.Duration
+}
+
+// Duration returns the delay before the next attempt and advances the attempt
+// counter.
+func (b *backoff) Duration() time.Duration {
+	b.attempt++
+	b.previous = b.delay(b.attempt, b.previous)
+	return b.previous
+}
+
+// Reset restarts the sequence from the first attempt.
+func (b *backoff) Reset() {
+	b.attempt = 0
+	b.previous = 0
+}
+
+// ForAttempt returns the delay before the next attempt after the given number
+// of failed attempts (starting at 1), without any saved state. The preceding
+// delays are recomputed so that strategies that depend on the previous delay
+// behave the same way as with Duration.
+func (b *backoff) ForAttempt(attempt int) time.Duration {
+	var delay time.Duration
+	for i := 1; i <= attempt; i++ {
+		delay = b.delay(i, delay)
+	}
+	return delay
+}
+
+func (b *backoff) delay(attempt int, previous time.Duration) time.Duration {
+	if b.Min >= b.Max {
+		return b.Max
+	}
+	delay := b.strategy.Delay(attempt, previous, b.Min, b.Max)
+	if delay < b.Min {
+		return b.Min
+	}
+	if delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
@@ -0,0 +1,200 @@
+package utility
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	const min = 10 * time.Millisecond
+	const max = time.Second
+
+	t.Run("Constant", func(t *testing.T) {
+		for attempt := 1; attempt <= 5; attempt++ {
+			assert.Equal(t, min, ConstantBackoff{}.Delay(attempt, 0, min, max))
+		}
+	})
+	t.Run("Linear", func(t *testing.T) {
+		assert.Equal(t, min, LinearBackoff{}.Delay(1, 0, min, max))
+		assert.Equal(t, 2*min, LinearBackoff{}.Delay(2, 0, min, max))
+		assert.Equal(t, 3*min, LinearBackoff{}.Delay(3, 0, min, max))
+		assert.Equal(t, min+2*time.Millisecond, LinearBackoff{Increment: 2 * time.Millisecond}.Delay(2, 0, min, max))
+	})
+	t.Run("Exponential", func(t *testing.T) {
+		assert.Equal(t, min, ExponentialBackoff{}.Delay(1, 0, min, max))
+		assert.Equal(t, 2*min, ExponentialBackoff{}.Delay(2, 0, min, max))
+		assert.Equal(t, 4*min, ExponentialBackoff{}.Delay(3, 0, min, max))
+		assert.Equal(t, 9*min, ExponentialBackoff{Factor: 3}.Delay(3, 0, min, max))
+	})
+	t.Run("ExponentialWithJitter", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			delay := ExponentialBackoff{Jitter: true}.Delay(3, 0, min, max)
+			assert.GreaterOrEqual(t, delay, min)
+			assert.LessOrEqual(t, delay, 4*min)
+		}
+	})
+	t.Run("DecorrelatedJitter", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			delay := DecorrelatedJitterBackoff{}.Delay(1, 0, min, max)
+			assert.GreaterOrEqual(t, delay, min)
+			assert.LessOrEqual(t, delay, 3*min)
+
+			delay = DecorrelatedJitterBackoff{}.Delay(2, 50*time.Millisecond, min, max)
+			assert.GreaterOrEqual(t, delay, min)
+			assert.LessOrEqual(t, delay, 150*time.Millisecond)
+		}
+	})
+	t.Run("SeededRandIsReproducible", func(t *testing.T) {
+		delays := func(strategy BackoffStrategy) []time.Duration {
+			var delays []time.Duration
+			var previous time.Duration
+			for attempt := 1; attempt <= 10; attempt++ {
+				previous = strategy.Delay(attempt, previous, min, max)
+				delays = append(delays, previous)
+			}
+			return delays
+		}
+
+		assert.Equal(t,
+			delays(ExponentialBackoff{Jitter: true, Rand: rand.New(rand.NewPCG(1, 2))}),
+			delays(ExponentialBackoff{Jitter: true, Rand: rand.New(rand.NewPCG(1, 2))}),
+		)
+		assert.Equal(t,
+			delays(DecorrelatedJitterBackoff{Rand: rand.New(rand.NewPCG(1, 2))}),
+			delays(DecorrelatedJitterBackoff{Rand: rand.New(rand.NewPCG(1, 2))}),
+		)
+		assert.NotEqual(t,
+			delays(DecorrelatedJitterBackoff{Rand: rand.New(rand.NewPCG(1, 2))}),
+			delays(DecorrelatedJitterBackoff{Rand: rand.New(rand.NewPCG(3, 4))}),
+		)
+	})
+	t.Run("Fibonacci", func(t *testing.T) {
+		var delays []time.Duration
+		for attempt := 1; attempt <= 7; attempt++ {
+			delays = append(delays, FibonacciBackoff{}.Delay(attempt, 0, min, max))
+		}
+		assert.Equal(t, []time.Duration{min, min, 2 * min, 3 * min, 5 * min, 8 * min, 13 * min}, delays)
+	})
+	t.Run("DoesNotOverflow", func(t *testing.T) {
+		assert.Positive(t, ExponentialBackoff{}.Delay(10000, 0, min, max))
+		assert.Positive(t, FibonacciBackoff{}.Delay(10000, 0, min, max))
+		assert.Positive(t, LinearBackoff{}.Delay(1<<62, 0, min, max))
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	t.Run("ClampsToBounds", func(t *testing.T) {
+		b := getBackoff(RetryOptions{
+			MinDelay: 10 * time.Millisecond,
+			MaxDelay: 30 * time.Millisecond,
+			Backoff: BackoffFunc(func(attempt int, _, _, _ time.Duration) time.Duration {
+				if attempt == 1 {
+					return time.Millisecond
+				}
+				return time.Hour
+			}),
+		})
+		assert.Equal(t, 10*time.Millisecond, b.Duration())
+		assert.Equal(t, 30*time.Millisecond, b.Duration())
+	})
+	t.Run("PassesPreviousDelay", func(t *testing.T) {
+		var previous []time.Duration
+		b := getBackoff(RetryOptions{
+			MinDelay: time.Millisecond,
+			MaxDelay: time.Second,
+			Backoff: BackoffFunc(func(attempt int, prev, _, _ time.Duration) time.Duration {
+				previous = append(previous, prev)
+				return time.Duration(attempt) * time.Millisecond
+			}),
+		})
+		for i := 0; i < 3; i++ {
+			b.Duration()
+		}
+		assert.Equal(t, []time.Duration{0, time.Millisecond, 2 * time.Millisecond}, previous)
+
+		b.Reset()
+		assert.Equal(t, time.Millisecond, b.Duration())
+	})
+	t.Run("ForAttemptMatchesDuration", func(t *testing.T) {
+		b := getBackoff(RetryOptions{MinDelay: time.Millisecond, MaxDelay: time.Second, Backoff: FibonacciBackoff{}})
+		for attempt := 1; attempt <= 10; attempt++ {
+			assert.Equal(t, b.Duration(), b.ForAttempt(attempt))
+		}
+	})
+	t.Run("DefaultsToExponentialWithJitter", func(t *testing.T) {
+		opts := RetryOptions{}
+		opts.Validate()
+		assert.Equal(t, defaultBackoffStrategy, opts.Backoff)
+		assert.Equal(t, defaultMinDelay, opts.MinDelay)
+	})
+	t.Run("AllowsMinDelayBelowDefault", func(t *testing.T) {
+		opts := RetryOptions{MinDelay: time.Millisecond}
+		opts.Validate()
+		assert.Equal(t, time.Millisecond, opts.MinDelay)
+	})
+}
+
+func TestRetryWithBackoffStrategy(t *testing.T) {
+	var delays []time.Duration
+	strategy := BackoffFunc(func(attempt int, _, min, _ time.Duration) time.Duration {
+		delay := time.Duration(attempt) * min
+		delays = append(delays, delay)
+		return delay
+	})
+
+	opts := RetryOptions{MaxAttempts: 4, MinDelay: time.Millisecond, MaxDelay: time.Second, Backoff: strategy}
+	err := Retry(context.Background(), func() (bool, error) {
+		return true, errors.New("something went wrong")
+	}, opts)
+	require.Error(t, err)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, delays)
+
+	t.Run("SeededRandReproducesRetryDelays", func(t *testing.T) {
+		retryDelays := func(seed uint64) []time.Duration {
+			var delays []time.Duration
+			opts := RetryOptions{
+				MaxAttempts: 5,
+				MinDelay:    time.Millisecond,
+				MaxDelay:    10 * time.Millisecond,
+				Backoff:     ExponentialBackoff{Jitter: true, Rand: rand.New(rand.NewPCG(seed, seed))},
+				OnRetry: func(_ int, _ error, delay time.Duration) {
+					delays = append(delays, delay)
+				},
+			}
+			err := Retry(context.Background(), func() (bool, error) {
+				return true, errors.New("something went wrong")
+			}, opts)
+			require.Error(t, err)
+			return delays
+		}
+
+		delays := retryDelays(42)
+		assert.Len(t, delays, 4)
+		assert.Equal(t, delays, retryDelays(42))
+	})
+
+	t.Run("RetryHTTPDelayUsesSameStrategy", func(t *testing.T) {
+		delayFn := RetryHTTPDelay(RetryOptions{MinDelay: time.Millisecond, MaxDelay: time.Second, Backoff: FibonacciBackoff{}})
+		req, err := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		require.NoError(t, err)
+
+		var httpDelays []time.Duration
+		for index := 0; index < 5; index++ {
+			httpDelays = append(httpDelays, delayFn(index, req, nil, nil))
+		}
+		assert.Equal(t, []time.Duration{
+			time.Millisecond,
+			time.Millisecond,
+			2 * time.Millisecond,
+			3 * time.Millisecond,
+			5 * time.Millisecond,
+		}, httpDelays)
+	})
+}
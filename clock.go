@@ -0,0 +1,237 @@
+package utility
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers, so that time-dependent code can
+// be tested deterministically by substituting a FakeClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that sends the current time on its channel
+	// after at least duration d.
+	NewTimer(d time.Duration) Timer
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// Sleep pauses the current goroutine for at least the duration d.
+	Sleep(d time.Duration)
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer
+	// fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns true if the call stops
+	// the timer, and false if the timer has already expired or been stopped.
+	Stop() bool
+	// Reset changes the timer to expire after duration d. It returns true if
+	// the timer had been active, and false if the timer had expired or been
+	// stopped.
+	Reset(d time.Duration) bool
+}
+
+// RealClock is a Clock backed by the system time.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time { return time.Now() }
+
+// NewTimer returns a Timer backed by time.NewTimer.
+func (RealClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// After returns time.After(d).
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sleep calls time.Sleep(d).
+func (RealClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// getClock returns the clock if it is set and the system clock otherwise.
+func getClock(clock Clock) Clock {
+	if clock == nil {
+		return RealClock{}
+	}
+	return clock
+}
+
+// contextWithClockDeadline is the same as context.WithDeadline, but the
+// deadline is measured by the clock. Contexts that cannot use a real deadline
+// are canceled with context.DeadlineExceeded as the cause instead.
+func contextWithClockDeadline(ctx context.Context, clock Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(RealClock); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := clock.NewTimer(deadline.Sub(clock.Now()))
+	go func() {
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		}
+	}()
+
+	return ctx, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// FakeClock is a Clock whose time only moves when it is advanced manually. It
+// is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{
+		now:    now,
+		timers: map[*fakeTimer]struct{}{},
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer returns a Timer that fires once the clock is advanced by at least
+// d. A timer with a non-positive duration fires immediately.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{
+		clock: c,
+		ch:    make(chan time.Time, 1),
+	}
+	t.Reset(d)
+	return t
+}
+
+// After returns the channel of a new Timer for duration d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+// Sleep blocks until the clock is advanced by at least d.
+func (c *FakeClock) Sleep(d time.Duration) { <-c.After(d) }
+
+// Advance moves the clock forward by d, firing every timer that expires in
+// that time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to the given time, firing every timer that expires by
+// then. Setting an earlier time moves Now backward, but does not fire any
+// timers, and timers that have already fired do not fire again.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(now)
+}
+
+func (c *FakeClock) setLocked(now time.Time) {
+	c.now = now
+	for t := range c.timers {
+		if !t.deadline.After(now) {
+			t.fireLocked(now)
+		}
+	}
+}
+
+// Waiters returns the number of active timers, including those created by
+// After and Sleep.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil blocks until there are at least n active timers or the context is
+// done. This lets tests wait for a goroutine to start waiting on the clock
+// before advancing it.
+func (c *FakeClock) BlockUntil(ctx context.Context, n int) error {
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.cond.Broadcast()
+	})
+	defer stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.cond.Wait()
+	}
+	return nil
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	ch       chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.timers[t]
+	t.deadline = t.clock.now.Add(d)
+	if d <= 0 {
+		t.fireLocked(t.clock.now)
+		return active
+	}
+
+	t.clock.timers[t] = struct{}{}
+	t.clock.cond.Broadcast()
+	return active
+}
+
+// fireLocked sends the time on the timer's channel and deactivates it. The
+// clock's lock must be held.
+func (t *fakeTimer) fireLocked(now time.Time) {
+	delete(t.clock.timers, t)
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
@@ -0,0 +1,175 @@
+package utility
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealClock(t *testing.T) {
+	var clock Clock = RealClock{}
+
+	t.Run("Now", func(t *testing.T) {
+		before := time.Now()
+		now := clock.Now()
+		assert.False(t, now.Before(before))
+	})
+	t.Run("TimerFires", func(t *testing.T) {
+		timer := clock.NewTimer(time.Millisecond)
+		select {
+		case <-timer.C():
+		case <-time.After(time.Second):
+			assert.Fail(t, "timer should have fired")
+		}
+		assert.False(t, timer.Stop())
+	})
+	t.Run("TimerStops", func(t *testing.T) {
+		timer := clock.NewTimer(time.Hour)
+		assert.True(t, timer.Stop())
+		assert.False(t, timer.Reset(time.Millisecond))
+		<-timer.C()
+	})
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("NowOnlyMovesWhenAdvanced", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		assert.Equal(t, start, clock.Now())
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+		clock.Set(start.Add(time.Hour))
+		assert.Equal(t, start.Add(time.Hour), clock.Now())
+	})
+	t.Run("SetCanMoveBackward", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		fired := clock.NewTimer(time.Minute)
+		pending := clock.NewTimer(time.Hour)
+		clock.Advance(time.Minute)
+		<-fired.C()
+
+		clock.Set(start)
+		assert.Equal(t, start, clock.Now())
+		select {
+		case <-fired.C():
+			assert.Fail(t, "fired timer should not fire again")
+		case <-pending.C():
+			assert.Fail(t, "timer should not fire when time moves backward")
+		default:
+		}
+		assert.Equal(t, 1, clock.Waiters())
+	})
+	t.Run("TimerFiresOnceDeadlinePasses", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		assert.Equal(t, 1, clock.Waiters())
+
+		clock.Advance(time.Second - 1)
+		select {
+		case <-timer.C():
+			assert.Fail(t, "timer should not fire before its deadline")
+		default:
+		}
+
+		clock.Advance(1)
+		select {
+		case now := <-timer.C():
+			assert.Equal(t, start.Add(time.Second), now)
+		default:
+			assert.Fail(t, "timer should fire at its deadline")
+		}
+		assert.Zero(t, clock.Waiters())
+		assert.False(t, timer.Stop())
+	})
+	t.Run("NonPositiveDurationFiresImmediately", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		select {
+		case <-clock.After(0):
+		default:
+			assert.Fail(t, "timer should fire immediately")
+		}
+		assert.Zero(t, clock.Waiters())
+	})
+	t.Run("StoppedTimerDoesNotFire", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		assert.True(t, timer.Stop())
+		assert.False(t, timer.Stop())
+
+		clock.Advance(time.Hour)
+		select {
+		case <-timer.C():
+			assert.Fail(t, "stopped timer should not fire")
+		default:
+		}
+	})
+	t.Run("ResetMovesDeadline", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		assert.True(t, timer.Reset(time.Minute))
+
+		clock.Advance(time.Second)
+		select {
+		case <-timer.C():
+			assert.Fail(t, "timer should not fire at its old deadline")
+		default:
+		}
+
+		clock.Advance(time.Minute)
+		<-timer.C()
+	})
+	t.Run("SleepBlocksUntilAdvanced", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		done := make(chan struct{})
+		go func() {
+			clock.Sleep(time.Minute)
+			close(done)
+		}()
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		require.NoError(t, clock.BlockUntil(ctx, 1))
+		select {
+		case <-done:
+			assert.Fail(t, "sleep should not return before the clock is advanced")
+		default:
+		}
+
+		clock.Advance(time.Minute)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			assert.Fail(t, "sleep should return once the clock is advanced")
+		}
+	})
+	t.Run("BlockUntilReturnsWhenContextIsDone", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, clock.BlockUntil(ctx, 1), context.DeadlineExceeded)
+	})
+	t.Run("ContextDeadline", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ctx, cancel := contextWithClockDeadline(t.Context(), clock, start.Add(time.Second))
+		defer cancel()
+
+		waitCtx, waitCancel := context.WithTimeout(t.Context(), time.Second)
+		defer waitCancel()
+		require.NoError(t, clock.BlockUntil(waitCtx, 1))
+		assert.NoError(t, ctx.Err())
+
+		clock.Advance(time.Second)
+		select {
+		case <-ctx.Done():
+			assert.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+		case <-time.After(time.Second):
+			assert.Fail(t, "context should be done once the deadline passes")
+		}
+	})
+}
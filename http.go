@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
@@ -358,11 +359,23 @@ type RetryRequestOptions struct {
 	RetryOn413 bool
 }
 
+// cancelOnCloseBody cancels the request context of a response once its
+// body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
 // RetryRequest takes an http.Request and makes the request until it's successful,
-// hits a max number of retries, or times out
+// hits a max number of retries, or times out. The AttemptTimeout and
+// MaxElapsedTime options interrupt an attempt that is still waiting for a
+// response, but not the reading of a response body that has been returned.
 func RetryRequest(ctx context.Context, r *http.Request, opts RetryRequestOptions) (*http.Response, error) {
-	r = r.WithContext(ctx)
-
 	// Save the entire request body so we can resend it on each attempt.
 	var requestBody []byte
 	if r.Body != nil {
@@ -377,24 +390,27 @@ func RetryRequest(ctx context.Context, r *http.Request, opts RetryRequestOptions
 	client := GetDefaultHTTPRetryableClient()
 	defer PutHTTPClient(client)
 
-	attempt := 1
-	var resp *http.Response
-	var err error
-
-	if err := Retry(ctx, func() (bool, error) {
-		defer func() {
-			attempt++
-		}()
+	return RetryValue(ctx, func(attemptCtx context.Context, _ int) (*http.Response, bool, error) {
+		// The response body may be read after the attempt finishes, so the
+		// attempt context only bounds the request until the response
+		// arrives. After that, the request is canceled when the body is
+		// closed.
+		reqCtx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(attemptCtx, cancel)
+		req := r.WithContext(reqCtx)
 
 		// Ensure the same body is attached for each attempt
 		if requestBody != nil {
-			r.Body = io.NopCloser(bytes.NewReader(requestBody))
+			req.Body = io.NopCloser(bytes.NewReader(requestBody))
 		}
 
-		resp, err = client.Do(r)
+		resp, err := client.Do(req)
+		stop()
 		if err != nil {
-			return true, err
+			cancel()
+			return resp, true, err
 		}
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			if opts.RetryOnInvalidBody {
@@ -403,39 +419,63 @@ func RetryRequest(ctx context.Context, r *http.Request, opts RetryRequestOptions
 				// Test if the body is valid by reading it.
 				body := &bytes.Buffer{}
 				if _, err := body.ReadFrom(resp.Body); err != nil {
-					return true, err
+					return resp, true, err
 				}
 
 				// If it is valid, reset the body so the caller can read it.
 				resp.Body = io.NopCloser(body)
 			}
-			return false, nil
+			return resp, false, nil
 		}
 
 		if resp.StatusCode == http.StatusRequestEntityTooLarge && opts.RetryOn413 {
-			return true, errors.Errorf("server returned status %d (request entity too large)", resp.StatusCode)
+			return resp, true, errors.Errorf("server returned status %d (request entity too large)", resp.StatusCode)
 		}
 
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return false, errors.Errorf("server returned status %d", resp.StatusCode)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return resp, false, errors.Errorf("server returned status %d", resp.StatusCode)
 		}
 
-		// if we get here it should most likely be a 5xx status code
+		// if we get here it should most likely be a 5xx status code, or a 429
+		// asking the client to slow down
 
-		return true, errors.Errorf("server returned status %d", resp.StatusCode)
-	}, opts.RetryOptions); err != nil {
-		return resp, err
-	}
+		err = errors.Errorf("server returned status %d", resp.StatusCode)
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), getClock(opts.Clock).Now()); ok {
+			err = RetryAfter(err, delay)
+		}
+		return resp, true, err
+	}, opts.RetryOptions)
+}
 
-	return resp, nil
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date, into the delay it requests from now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
 }
 
-// RetryHTTPDelay returns the function that generates the exponential backoff
-// delay between retried HTTP requests.
+// RetryHTTPDelay returns the function that generates the backoff delay
+// between retried HTTP requests using the options' backoff strategy, so that
+// HTTP requests are retried with the same delays as Retry.
 func RetryHTTPDelay(opts RetryOptions) HTTPDelayFunction {
 	backoff := getBackoff(opts)
 	return func(index int, req *http.Request, resp *http.Response, err error) time.Duration {
-		return backoff.ForAttempt(float64(index))
+		return backoff.ForAttempt(index + 1)
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/rehttp"
 	"github.com/stretchr/testify/assert"
@@ -186,6 +187,79 @@ func TestRetryRequestOn413(t *testing.T) {
 	assert.GreaterOrEqual(t, atomic.LoadInt32(&callCount), int32(2), "expected multiple attempts")
 }
 
+func TestRetryRequestOn429(t *testing.T) {
+	var callCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&callCount, 1) == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	opts := RetryRequestOptions{
+		RetryOptions: RetryOptions{
+			MaxAttempts: 2,
+			MinDelay:    time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+			OnRetry: func(_ int, _ error, delay time.Duration) {
+				delays = append(delays, delay)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := RetryRequest(t.Context(), req, opts)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&callCount))
+	assert.Equal(t, []time.Duration{10 * time.Millisecond}, delays, "the Retry-After delay should be honored up to the maximum delay")
+}
+
+func TestRetryRequestAttemptTimeout(t *testing.T) {
+	var callCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&callCount, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	opts := RetryRequestOptions{
+		RetryOptions: RetryOptions{
+			MaxAttempts:    2,
+			MinDelay:       time.Millisecond,
+			MaxDelay:       time.Millisecond,
+			AttemptTimeout: 50 * time.Millisecond,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := RetryRequest(t.Context(), req, opts)
+	require.NoError(t, err, "the hung attempt should time out and be retried")
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "the body should be readable after the attempt timeout passes")
+	assert.Equal(t, "ok", string(body))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&callCount))
+}
+
 func TestMockHandler(t *testing.T) {
 	handler := NewMockHandler()
 	server := httptest.NewServer(handler)
@@ -288,3 +362,27 @@ func TestRetryRequestBodyRemainsValidOnSecondAttempt(t *testing.T) {
 	assert.Contains(t, string(data), "request body data", "expected second attempt to see same request body")
 
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Run("Seconds", func(t *testing.T) {
+		delay, ok := parseRetryAfter("120", time.Now())
+		assert.True(t, ok)
+		assert.Equal(t, 2*time.Minute, delay)
+	})
+	t.Run("HTTPDate", func(t *testing.T) {
+		delay, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Now())
+		assert.True(t, ok)
+		assert.InDelta(t, float64(time.Hour), float64(delay), float64(5*time.Second))
+	})
+	t.Run("PastHTTPDate", func(t *testing.T) {
+		delay, ok := parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), time.Now())
+		assert.True(t, ok)
+		assert.Zero(t, delay)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, value := range []string{"", "-1", "soon"} {
+			_, ok := parseRetryAfter(value, time.Now())
+			assert.False(t, ok, value)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package utility
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 
@@ -10,9 +11,94 @@ import (
 const maxRequestSize = 16 * 1024 * 1024  // 16 MB
 const maxResponseSize = 16 * 1024 * 1024 // 16 MB
 
+// ErrBodyTooLarge is returned by size-limited readers created with
+// ErrorOnOverflow set once the body exceeds the configured limit.
+type ErrBodyTooLarge struct {
+	// Limit is the maximum number of bytes the reader allows.
+	Limit int64
+	// BytesRead is the number of bytes read from the underlying body when the
+	// limit was exceeded.
+	BytesRead int64
+}
+
+func (e *ErrBodyTooLarge) Error() string {
+	return fmt.Sprintf("body exceeds the limit of %d bytes (read %d bytes)", e.Limit, e.BytesRead)
+}
+
+// LimitedReaderOptions configures the behavior of the size-limited readers
+// for request and response bodies.
+type LimitedReaderOptions struct {
+	// Size is the maximum number of bytes that can be read from the body. By
+	// default, it is 16 megabytes.
+	Size int64
+	// ErrorOnOverflow makes the reader return an *ErrBodyTooLarge once more
+	// than Size bytes are read, rather than silently returning io.EOF at the
+	// limit.
+	ErrorOnOverflow bool
+	// ResponseWriter, if set, has a 413 (request entity too large) response
+	// written to it the first time the limit is exceeded. Setting this
+	// implies ErrorOnOverflow. Handlers should stop writing to the response
+	// once the reader returns an *ErrBodyTooLarge.
+	ResponseWriter http.ResponseWriter
+}
+
+func (o *LimitedReaderOptions) validate(defaultSize int64) {
+	if o.Size <= 0 {
+		o.Size = defaultSize
+	}
+	if o.ResponseWriter != nil {
+		o.ErrorOnOverflow = true
+	}
+}
+
+func newLimitedReader(r io.Reader, opts LimitedReaderOptions) io.Reader {
+	if !opts.ErrorOnOverflow {
+		return &io.LimitedReader{R: r, N: opts.Size}
+	}
+	return &overflowReader{
+		r:     r,
+		limit: opts.Size,
+		w:     opts.ResponseWriter,
+	}
+}
+
+// overflowReader is similar to an io.LimitedReader but reads one byte past the
+// limit so that it can distinguish a body that exactly fits the limit from one
+// that exceeds it.
+type overflowReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+	w     http.ResponseWriter
+	err   error
+}
+
+func (r *overflowReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if remaining := r.limit + 1 - r.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.read <= r.limit {
+		return n, err
+	}
+
+	r.err = &ErrBodyTooLarge{Limit: r.limit, BytesRead: r.read}
+	if r.w != nil {
+		http.Error(r.w, r.err.Error(), http.StatusRequestEntityTooLarge)
+	}
+
+	return n - int(r.read-r.limit), r.err
+}
+
 type requestReader struct {
 	req *http.Request
-	*io.LimitedReader
+	io.Reader
 }
 
 // NewRequestReader returns an io.ReadCloser closer for the body of an
@@ -27,20 +113,30 @@ func NewRequestReader(req *http.Request) io.ReadCloser {
 func NewRequestReaderWithSize(req *http.Request, size int64) io.ReadCloser {
 	return &requestReader{
 		req: req,
-		LimitedReader: &io.LimitedReader{
+		Reader: &io.LimitedReader{
 			R: req.Body,
 			N: size,
 		},
 	}
 }
 
+// NewRequestReaderWithOptions returns an io.ReadCloser closer for the body of
+// an *http.Request, limited according to the given options.
+func NewRequestReaderWithOptions(req *http.Request, opts LimitedReaderOptions) io.ReadCloser {
+	opts.validate(maxRequestSize)
+	return &requestReader{
+		req:    req,
+		Reader: newLimitedReader(req.Body, opts),
+	}
+}
+
 func (r *requestReader) Close() error {
 	return errors.WithStack(r.req.Body.Close())
 }
 
 type responseReader struct {
 	req *http.Response
-	*io.LimitedReader
+	io.Reader
 }
 
 // NewResponseReader returns an io.ReadCloser closer for the body of an
@@ -55,13 +151,24 @@ func NewResponseReader(req *http.Response) io.ReadCloser {
 func NewResponseReaderWithSize(req *http.Response, size int64) io.ReadCloser {
 	return &responseReader{
 		req: req,
-		LimitedReader: &io.LimitedReader{
+		Reader: &io.LimitedReader{
 			R: req.Body,
 			N: size,
 		},
 	}
 }
 
+// NewResponseReaderWithOptions returns an io.ReadCloser closer for the body of
+// an *http.Response, limited according to the given options. The
+// ResponseWriter option has no meaningful use for client responses.
+func NewResponseReaderWithOptions(req *http.Response, opts LimitedReaderOptions) io.ReadCloser {
+	opts.validate(maxResponseSize)
+	return &responseReader{
+		req:    req,
+		Reader: newLimitedReader(req.Body, opts),
+	}
+}
+
 func (r *responseReader) Close() error {
 	return errors.WithStack(r.req.Body.Close())
 }
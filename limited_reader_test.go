@@ -0,0 +1,89 @@
+package utility
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedReaders(t *testing.T) {
+	newRequest := func(t *testing.T, body string) *http.Request {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, "http://localhost", strings.NewReader(body))
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("DefaultModeTruncatesSilently", func(t *testing.T) {
+		r := NewRequestReaderWithSize(newRequest(t, "0123456789"), 4)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "0123", string(data))
+	})
+	t.Run("ErrorOnOverflowReturnsTypedError", func(t *testing.T) {
+		r := NewRequestReaderWithOptions(newRequest(t, "0123456789"), LimitedReaderOptions{Size: 4, ErrorOnOverflow: true})
+		data, err := io.ReadAll(r)
+		require.Error(t, err)
+		assert.Equal(t, "0123", string(data))
+
+		var tooLarge *ErrBodyTooLarge
+		require.True(t, errors.As(err, &tooLarge))
+		assert.EqualValues(t, 4, tooLarge.Limit)
+		assert.EqualValues(t, 5, tooLarge.BytesRead)
+
+		_, err = r.Read(make([]byte, 1))
+		assert.True(t, MatchesError[*ErrBodyTooLarge](err), "subsequent reads should keep returning the overflow error")
+	})
+	t.Run("ErrorOnOverflowAllowsBodyExactlyAtLimit", func(t *testing.T) {
+		r := NewRequestReaderWithOptions(newRequest(t, "0123"), LimitedReaderOptions{Size: 4, ErrorOnOverflow: true})
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "0123", string(data))
+	})
+	t.Run("ResponseReaderErrorOnOverflow", func(t *testing.T) {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader("0123456789"))}
+		r := NewResponseReaderWithOptions(resp, LimitedReaderOptions{Size: 8, ErrorOnOverflow: true})
+		_, err := io.ReadAll(r)
+		assert.True(t, MatchesError[*ErrBodyTooLarge](err))
+		assert.NoError(t, r.Close())
+	})
+	t.Run("WritesStatusToResponseWriter", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := NewRequestReaderWithOptions(r, LimitedReaderOptions{Size: 4, ResponseWriter: w})
+			defer body.Close()
+			if _, err := io.ReadAll(body); err != nil {
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(t, "0123456789"))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(t, "0123"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+	t.Run("ReadJSONSurfacesOverflow", func(t *testing.T) {
+		r := NewRequestReaderWithOptions(newRequest(t, `{"key": "a long value"}`), LimitedReaderOptions{Size: 8, ErrorOnOverflow: true})
+		out := map[string]string{}
+		err := ReadJSON(r, &out)
+		require.Error(t, err)
+		assert.True(t, MatchesError[*ErrBodyTooLarge](err))
+		assert.Empty(t, out)
+	})
+	t.Run("ReadYAMLSurfacesOverflow", func(t *testing.T) {
+		r := NewRequestReaderWithOptions(newRequest(t, "key: a long value"), LimitedReaderOptions{Size: 8, ErrorOnOverflow: true})
+		out := map[string]string{}
+		err := ReadYAML(r, &out)
+		require.Error(t, err)
+		assert.True(t, MatchesError[*ErrBodyTooLarge](err))
+		assert.Empty(t, out)
+	})
+}
@@ -0,0 +1,416 @@
+package utility
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	limiterInstrumentationName = "github.com/evergreen-ci/utility/limiter"
+
+	defaultLimiterInitialLimit = 20
+	defaultLimiterMinLimit     = 1
+	defaultLimiterMaxLimit     = 1000
+	defaultLimiterBackoffRatio = 0.9
+)
+
+const limiterNameAttribute = "evergreen.limiter.name"
+
+// Names of the metrics recorded by adaptive limiters.
+const (
+	LimiterLimitMetric      = "evergreen.limiter.limit"
+	LimiterInFlightMetric   = "evergreen.limiter.in_flight"
+	LimiterDropsMetric      = "evergreen.limiter.drops"
+	LimiterRejectionsMetric = "evergreen.limiter.rejections"
+)
+
+// ErrLimiterClosed is returned when acquiring capacity from a closed limiter.
+var ErrLimiterClosed = errors.New("limiter is closed")
+
+// AdaptiveLimiterOptions configure an AdaptiveLimiter.
+type AdaptiveLimiterOptions struct {
+	// Name identifies the limiter in its metrics.
+	Name string
+	// InitialLimit is the number of operations allowed in flight when the
+	// limiter is created. By default, it is 20.
+	InitialLimit int
+	// MinLimit is the lowest the limit can shrink to. By default, it is 1.
+	MinLimit int
+	// MaxLimit is the highest the limit can grow to. By default, it is 1000.
+	MaxLimit int
+	// BackoffRatio is the factor the limit is multiplied by when an operation
+	// is dropped. It must be in (0, 1). By default, it is 0.9.
+	BackoffRatio float64
+	// LatencyThreshold, if set, is the latency above which a successful
+	// operation is treated as a drop, so that the limit shrinks when latency
+	// rises.
+	LatencyThreshold time.Duration
+	// IsDrop decides whether an operation's error indicates overload and
+	// should shrink the limit. By default, every error is a drop except for
+	// errors marked with Permanent, matching the errors that Retry would
+	// retry. Operations that fail because their context was canceled never
+	// affect the limit.
+	IsDrop ErrorClassifier
+	// Clock is used to measure latency. By default, it is the system clock.
+	Clock Clock
+	// MeterProvider provides the meter for the limiter's metrics. By default,
+	// it is the global meter provider.
+	MeterProvider metric.MeterProvider
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *AdaptiveLimiterOptions) Validate() error {
+	if o.MinLimit == 0 {
+		o.MinLimit = defaultLimiterMinLimit
+	}
+	if o.MaxLimit == 0 {
+		o.MaxLimit = defaultLimiterMaxLimit
+	}
+	if o.InitialLimit == 0 {
+		o.InitialLimit = min(max(defaultLimiterInitialLimit, o.MinLimit), o.MaxLimit)
+	}
+	if o.BackoffRatio == 0 {
+		o.BackoffRatio = defaultLimiterBackoffRatio
+	}
+	if o.IsDrop == nil {
+		o.IsDrop = isLimiterDrop
+	}
+	o.Clock = getClock(o.Clock)
+	if o.MeterProvider == nil {
+		o.MeterProvider = otel.GetMeterProvider()
+	}
+
+	if o.MinLimit < 1 {
+		return errors.Errorf("minimum limit must be positive, but is %d", o.MinLimit)
+	}
+	if o.MaxLimit < o.MinLimit {
+		return errors.Errorf("maximum limit %d must not be less than minimum limit %d", o.MaxLimit, o.MinLimit)
+	}
+	if o.InitialLimit < o.MinLimit || o.InitialLimit > o.MaxLimit {
+		return errors.Errorf("initial limit %d must be between the minimum limit %d and the maximum limit %d", o.InitialLimit, o.MinLimit, o.MaxLimit)
+	}
+	if o.BackoffRatio <= 0 || o.BackoffRatio >= 1 {
+		return errors.Errorf("backoff ratio must be between 0 and 1, but is %g", o.BackoffRatio)
+	}
+	if o.LatencyThreshold < 0 {
+		return errors.New("latency threshold must not be negative")
+	}
+	return nil
+}
+
+// isLimiterDrop is the default drop classifier.
+func isLimiterDrop(err error) bool {
+	return !IsPermanent(err)
+}
+
+// AdaptiveLimiter limits the number of operations in flight with a limit that
+// adapts to their outcomes using additive increase, multiplicative decrease
+// (AIMD). Each successful operation grows the limit by one while the limiter
+// is at least half utilized, and each dropped operation, such as one that
+// fails with a retryable error or exceeds the latency threshold, shrinks it by
+// the backoff ratio. This sheds load from a struggling dependency, which
+// retries alone do not do. It is safe for concurrent use.
+type AdaptiveLimiter struct {
+	opts AdaptiveLimiterOptions
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	closed   bool
+	// changed is closed and replaced whenever capacity may have become
+	// available, waking up waiting callers.
+	changed chan struct{}
+
+	attributes   metric.MeasurementOption
+	drops        metric.Int64Counter
+	rejections   metric.Int64Counter
+	registration metric.Registration
+}
+
+// NewAdaptiveLimiter returns a new limiter. Call Close once the limiter is no
+// longer needed to stop reporting its metrics.
+func NewAdaptiveLimiter(opts AdaptiveLimiterOptions) (*AdaptiveLimiter, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	l := &AdaptiveLimiter{
+		opts:       opts,
+		limit:      float64(opts.InitialLimit),
+		changed:    make(chan struct{}),
+		attributes: metric.WithAttributes(attribute.String(limiterNameAttribute, opts.Name)),
+	}
+	if err := l.registerMetrics(); err != nil {
+		return nil, errors.Wrap(err, "registering metrics")
+	}
+
+	return l, nil
+}
+
+func (l *AdaptiveLimiter) registerMetrics() error {
+	meter := l.opts.MeterProvider.Meter(limiterInstrumentationName)
+
+	limitGauge, err := meter.Int64ObservableGauge(LimiterLimitMetric,
+		metric.WithDescription("Current number of operations the limiter allows in flight."),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s gauge", LimiterLimitMetric)
+	}
+	inFlightGauge, err := meter.Int64ObservableGauge(LimiterInFlightMetric,
+		metric.WithDescription("Number of operations currently in flight."),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s gauge", LimiterInFlightMetric)
+	}
+	l.drops, err = meter.Int64Counter(LimiterDropsMetric,
+		metric.WithDescription("Number of operations that were dropped, shrinking the limit."),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", LimiterDropsMetric)
+	}
+	l.rejections, err = meter.Int64Counter(LimiterRejectionsMetric,
+		metric.WithDescription("Number of operations that were rejected because the limit was reached."),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", LimiterRejectionsMetric)
+	}
+
+	l.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		limit, inFlight := l.State()
+		o.ObserveInt64(limitGauge, int64(limit), l.attributes)
+		o.ObserveInt64(inFlightGauge, int64(inFlight), l.attributes)
+		return nil
+	}, limitGauge, inFlightGauge)
+	return errors.Wrap(err, "registering gauge callback")
+}
+
+// State returns the current limit and the number of operations in flight.
+func (l *AdaptiveLimiter) State() (limit, inFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit), l.inFlight
+}
+
+// Acquire waits until the limiter allows another operation in flight or the
+// context is done. The returned token must be released once the operation
+// finishes.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (*LimiterToken, error) {
+	for {
+		token, changed, err := l.tryAcquire()
+		if token != nil || err != nil {
+			return token, err
+		}
+
+		select {
+		case <-ctx.Done():
+			l.rejections.Add(context.WithoutCancel(ctx), 1, l.attributes)
+			return nil, errors.Wrap(ctx.Err(), "waiting for limiter capacity")
+		case <-changed:
+		}
+	}
+}
+
+// TryAcquire is the same as Acquire but returns false immediately instead of
+// waiting if the limit has been reached.
+func (l *AdaptiveLimiter) TryAcquire() (*LimiterToken, bool) {
+	token, _, _ := l.tryAcquire()
+	if token == nil {
+		l.rejections.Add(context.Background(), 1, l.attributes)
+		return nil, false
+	}
+	return token, true
+}
+
+// tryAcquire acquires capacity if it is available. Otherwise, it returns a
+// channel that is closed once capacity may have become available.
+func (l *AdaptiveLimiter) tryAcquire() (*LimiterToken, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, nil, ErrLimiterClosed
+	}
+	if l.inFlight >= int(l.limit) {
+		return nil, l.changed, nil
+	}
+
+	l.inFlight++
+	return &LimiterToken{
+		limiter:  l,
+		start:    l.opts.Clock.Now(),
+		inFlight: l.inFlight,
+	}, nil, nil
+}
+
+// limiterOutcome is how a finished operation affects the limit.
+type limiterOutcome int
+
+const (
+	limiterOutcomeSuccess limiterOutcome = iota
+	limiterOutcomeDrop
+	limiterOutcomeIgnore
+)
+
+// release returns the capacity of an operation that was started when the
+// given number of operations were in flight, and adjusts the limit according
+// to its outcome.
+func (l *AdaptiveLimiter) release(inFlight int, outcome limiterOutcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	switch {
+	case outcome == limiterOutcomeDrop:
+		l.limit = math.Max(float64(l.opts.MinLimit), math.Floor(l.limit*l.opts.BackoffRatio))
+	case outcome == limiterOutcomeSuccess && inFlight*2 >= int(l.limit):
+		l.limit = math.Min(float64(l.opts.MaxLimit), l.limit+1)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Do runs the operation once the limiter allows it, and releases its capacity
+// with the operation's error once it returns. If the operation panics, its
+// capacity is dropped before the panic continues.
+func (l *AdaptiveLimiter) Do(ctx context.Context, op func(context.Context) error) (err error) {
+	token, err := l.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			token.Drop()
+			panic(r)
+		}
+		token.Release(err)
+	}()
+
+	return op(ctx)
+}
+
+// Close stops reporting the limiter's metrics. Operations that are in flight
+// can still be released, but no more capacity can be acquired.
+func (l *AdaptiveLimiter) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	close(l.changed)
+	l.changed = make(chan struct{})
+
+	return errors.Wrap(l.registration.Unregister(), "unregistering metrics callback")
+}
+
+// RoundTripper returns an http.RoundTripper that limits the requests sent
+// through next. A request is dropped if it fails or the server responds with
+// a 429 (too many requests) or 5xx status. The request's capacity is released
+// once the response headers are received, so reading the body does not count
+// toward the limit. If next is nil, http.DefaultTransport is used.
+func (l *AdaptiveLimiter) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &limiterTransport{limiter: l, next: next}
+}
+
+type limiterTransport struct {
+	limiter *AdaptiveLimiter
+	next    http.RoundTripper
+}
+
+func (t *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.limiter.Acquire(req.Context())
+	if err != nil {
+		// A RoundTripper must always close the request body.
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		token.Release(err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		token.Drop()
+	default:
+		token.Release(nil)
+	}
+	return resp, err
+}
+
+// LimiterToken represents capacity acquired from an AdaptiveLimiter for a
+// single operation. Only the first call to Release, Drop or Ignore has an
+// effect.
+type LimiterToken struct {
+	limiter  *AdaptiveLimiter
+	start    time.Time
+	inFlight int
+	released atomic.Bool
+}
+
+// Release returns the capacity to the limiter and records the operation's
+// outcome. The limit shrinks if the error is a drop according to the
+// limiter's options or the operation exceeded the latency threshold, and grows
+// otherwise. If the error is due to context cancellation, the limit is not
+// changed.
+func (t *LimiterToken) Release(err error) {
+	if errors.Is(err, context.Canceled) {
+		t.Ignore()
+		return
+	}
+	if err != nil && t.limiter.opts.IsDrop(err) {
+		t.Drop()
+		return
+	}
+
+	latency := t.limiter.opts.Clock.Now().Sub(t.start)
+	if threshold := t.limiter.opts.LatencyThreshold; threshold > 0 && latency > threshold {
+		t.Drop()
+		return
+	}
+	if t.released.Swap(true) {
+		return
+	}
+	t.limiter.release(t.inFlight, limiterOutcomeSuccess)
+}
+
+// Drop returns the capacity to the limiter and shrinks the limit, regardless
+// of how the operation finished.
+func (t *LimiterToken) Drop() {
+	if t.released.Swap(true) {
+		return
+	}
+	t.limiter.drops.Add(context.Background(), 1, t.limiter.attributes)
+	t.limiter.release(t.inFlight, limiterOutcomeDrop)
+}
+
+// Ignore returns the capacity to the limiter without affecting the limit,
+// such as for operations that were canceled before they could succeed or
+// fail.
+func (t *LimiterToken) Ignore() {
+	if t.released.Swap(true) {
+		return
+	}
+	t.limiter.release(t.inFlight, limiterOutcomeIgnore)
+}
@@ -0,0 +1,314 @@
+package utility
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestAdaptiveLimiterOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		var opts AdaptiveLimiterOptions
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, defaultLimiterInitialLimit, opts.InitialLimit)
+		assert.Equal(t, defaultLimiterMinLimit, opts.MinLimit)
+		assert.Equal(t, defaultLimiterMaxLimit, opts.MaxLimit)
+		assert.Equal(t, defaultLimiterBackoffRatio, opts.BackoffRatio)
+		assert.NotNil(t, opts.IsDrop)
+		assert.NotNil(t, opts.Clock)
+		assert.NotNil(t, opts.MeterProvider)
+	})
+	t.Run("DefaultInitialLimitIsWithinBounds", func(t *testing.T) {
+		opts := AdaptiveLimiterOptions{MaxLimit: 5}
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, 5, opts.InitialLimit)
+	})
+	t.Run("RejectsInvalidOptions", func(t *testing.T) {
+		for name, opts := range map[string]AdaptiveLimiterOptions{
+			"NegativeMinLimit":        {MinLimit: -1},
+			"MaxLimitBelowMinLimit":   {MinLimit: 10, MaxLimit: 5},
+			"InitialLimitOutOfBounds": {InitialLimit: 50, MaxLimit: 10},
+			"BackoffRatioTooLarge":    {BackoffRatio: 1.5},
+			"NegativeLatency":         {LatencyThreshold: -time.Second},
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.Error(t, opts.Validate())
+			})
+		}
+	})
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	newLimiter := func(t *testing.T, opts AdaptiveLimiterOptions) *AdaptiveLimiter {
+		l, err := NewAdaptiveLimiter(opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, l.Close()) })
+		return l
+	}
+
+	t.Run("GrowsOnSuccessWhenUtilized", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 2, MaxLimit: 3})
+
+		token, err := l.Acquire(t.Context())
+		require.NoError(t, err)
+		token.Release(nil)
+		limit, inFlight := l.State()
+		assert.Equal(t, 3, limit)
+		assert.Zero(t, inFlight)
+
+		token, err = l.Acquire(t.Context())
+		require.NoError(t, err)
+		token.Release(nil)
+		limit, _ = l.State()
+		assert.Equal(t, 3, limit, "limit should not grow past the maximum")
+	})
+	t.Run("DoesNotGrowWhenUnderutilized", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 10})
+
+		require.NoError(t, l.Do(t.Context(), func(context.Context) error { return nil }))
+		limit, _ := l.State()
+		assert.Equal(t, 10, limit)
+	})
+	t.Run("ShrinksOnRetryableError", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 10, BackoffRatio: 0.5, MinLimit: 2})
+
+		err := l.Do(t.Context(), func(context.Context) error { return errors.New("unavailable") })
+		require.Error(t, err)
+		limit, inFlight := l.State()
+		assert.Equal(t, 5, limit)
+		assert.Zero(t, inFlight)
+
+		for i := 0; i < 5; i++ {
+			_ = l.Do(t.Context(), func(context.Context) error { return errors.New("unavailable") })
+		}
+		limit, _ = l.State()
+		assert.Equal(t, 2, limit, "limit should not shrink past the minimum")
+	})
+	t.Run("PermanentErrorsDoNotShrink", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 1})
+
+		err := l.Do(t.Context(), func(context.Context) error { return Permanent(errors.New("bad request")) })
+		require.Error(t, err)
+		limit, _ := l.State()
+		assert.Equal(t, 2, limit)
+	})
+	t.Run("CanceledOperationsDoNotAffectLimit", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 1})
+
+		err := l.Do(t.Context(), func(context.Context) error { return context.Canceled })
+		require.Error(t, err)
+		limit, inFlight := l.State()
+		assert.Equal(t, 1, limit)
+		assert.Zero(t, inFlight)
+	})
+	t.Run("CustomDropClassifier", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{
+			InitialLimit: 10,
+			IsDrop:       func(err error) bool { return err.Error() == "overloaded" },
+		})
+
+		_ = l.Do(t.Context(), func(context.Context) error { return errors.New("not found") })
+		limit, _ := l.State()
+		assert.Equal(t, 10, limit)
+
+		_ = l.Do(t.Context(), func(context.Context) error { return errors.New("overloaded") })
+		limit, _ = l.State()
+		assert.Equal(t, 9, limit)
+	})
+	t.Run("ShrinksWhenLatencyExceedsThreshold", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 10, BackoffRatio: 0.5, LatencyThreshold: time.Second, Clock: clock})
+
+		require.NoError(t, l.Do(t.Context(), func(context.Context) error {
+			clock.Advance(2 * time.Second)
+			return nil
+		}))
+		limit, _ := l.State()
+		assert.Equal(t, 5, limit)
+	})
+	t.Run("AcquireWaitsForCapacity", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 1})
+
+		token, err := l.Acquire(t.Context())
+		require.NoError(t, err)
+		_, ok := l.TryAcquire()
+		assert.False(t, ok)
+
+		acquired := make(chan *LimiterToken)
+		go func() {
+			token, err := l.Acquire(t.Context())
+			assert.NoError(t, err)
+			acquired <- token
+		}()
+
+		select {
+		case <-acquired:
+			assert.Fail(t, "acquire should wait while the limit is reached")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		token.Ignore()
+		select {
+		case token := <-acquired:
+			require.NotNil(t, token)
+			token.Ignore()
+		case <-time.After(time.Second):
+			assert.Fail(t, "acquire should succeed once capacity is released")
+		}
+	})
+	t.Run("AcquireStopsWhenContextIsDone", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 1})
+		token, ok := l.TryAcquire()
+		require.True(t, ok)
+		defer token.Ignore()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		_, err := l.Acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("DoReleasesCapacityWhenOperationPanics", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 2})
+
+		assert.PanicsWithValue(t, "operation failed", func() {
+			_ = l.Do(t.Context(), func(context.Context) error { panic("operation failed") })
+		})
+		limit, inFlight := l.State()
+		assert.LessOrEqual(t, limit, 2, "a panic should not count as a success")
+		assert.Zero(t, inFlight)
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		assert.NoError(t, l.Do(ctx, func(context.Context) error { return nil }))
+	})
+	t.Run("TokenIsReleasedOnce", func(t *testing.T) {
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 4})
+		token, ok := l.TryAcquire()
+		require.True(t, ok)
+
+		token.Drop()
+		token.Drop()
+		token.Release(nil)
+		limit, inFlight := l.State()
+		assert.Equal(t, 3, limit)
+		assert.Zero(t, inFlight)
+	})
+	t.Run("ClosedLimiterRejectsOperations", func(t *testing.T) {
+		l, err := NewAdaptiveLimiter(AdaptiveLimiterOptions{})
+		require.NoError(t, err)
+		require.NoError(t, l.Close())
+		require.NoError(t, l.Close())
+
+		_, err = l.Acquire(t.Context())
+		assert.ErrorIs(t, err, ErrLimiterClosed)
+	})
+	t.Run("RoundTripper", func(t *testing.T) {
+		status := http.StatusOK
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer srv.Close()
+
+		l := newLimiter(t, AdaptiveLimiterOptions{InitialLimit: 10})
+		client := &http.Client{Transport: l.RoundTripper(nil)}
+		get := func() {
+			resp, err := client.Get(srv.URL)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+
+		get()
+		limit, inFlight := l.State()
+		assert.Equal(t, 10, limit)
+		assert.Zero(t, inFlight)
+
+		status = http.StatusServiceUnavailable
+		get()
+		limit, _ = l.State()
+		assert.Equal(t, 9, limit)
+
+		status = http.StatusTooManyRequests
+		get()
+		limit, _ = l.State()
+		assert.Equal(t, 8, limit)
+
+		status = http.StatusNotFound
+		get()
+		limit, _ = l.State()
+		assert.Equal(t, 8, limit)
+	})
+	t.Run("RoundTripperClosesBodyWhenClosed", func(t *testing.T) {
+		l, err := NewAdaptiveLimiter(AdaptiveLimiterOptions{})
+		require.NoError(t, err)
+		require.NoError(t, l.Close())
+
+		body := &myReadCloser{Reader: strings.NewReader("body")}
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, "http://localhost", body)
+		require.NoError(t, err)
+
+		_, err = l.RoundTripper(nil).RoundTrip(req)
+		assert.ErrorIs(t, err, ErrLimiterClosed)
+		assert.True(t, body.closed, "the request body should be closed")
+	})
+	t.Run("ExportsMetrics", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		l := newLimiter(t, AdaptiveLimiterOptions{
+			Name:          "backend",
+			InitialLimit:  2,
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+
+		token, ok := l.TryAcquire()
+		require.True(t, ok)
+		_, err := l.Acquire(t.Context())
+		require.NoError(t, err)
+		_, ok = l.TryAcquire()
+		require.False(t, ok)
+		token.Drop()
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(t.Context(), &rm))
+		values := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Gauge[int64]:
+					require.Len(t, data.DataPoints, 1)
+					assert.Contains(t, data.DataPoints[0].Attributes.ToSlice(), attribute.String(limiterNameAttribute, "backend"))
+					values[m.Name] = data.DataPoints[0].Value
+				case metricdata.Sum[int64]:
+					require.Len(t, data.DataPoints, 1)
+					assert.Contains(t, data.DataPoints[0].Attributes.ToSlice(), attribute.String(limiterNameAttribute, "backend"))
+					values[m.Name] = data.DataPoints[0].Value
+				}
+			}
+		}
+		assert.Equal(t, map[string]int64{
+			LimiterLimitMetric:      1,
+			LimiterInFlightMetric:   1,
+			LimiterDropsMetric:      1,
+			LimiterRejectionsMetric: 1,
+		}, values)
+	})
+}
+
+type myReadCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (r *myReadCloser) Close() error {
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/evergreen-ci/utility"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const requestAttribute = "evergreen.http.request"
+
+var (
+	requestIDAttribute     = fmt.Sprintf("%s.id", requestAttribute)
+	requestMethodAttribute = fmt.Sprintf("%s.method", requestAttribute)
+	requestPathAttribute   = fmt.Sprintf("%s.path", requestAttribute)
+)
+
+// AttributesFunc returns the attributes that describe a request.
+type AttributesFunc func(r *http.Request) []attribute.KeyValue
+
+// DefaultRequestAttributes returns the request ID (if any), method and path of
+// the request as attributes.
+func DefaultRequestAttributes(r *http.Request) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String(requestMethodAttribute, r.Method),
+		attribute.String(requestPathAttribute, r.URL.Path),
+	}
+	if id := GetRequestID(r.Context()); id != "" {
+		attrs = append(attrs, attribute.String(requestIDAttribute, id))
+	}
+	return attrs
+}
+
+// Attributes appends the attributes returned by fn to the request context
+// using utility.ContextWithAppendedAttributes, so that every span created
+// while serving the request carries them when the tracer provider uses
+// utility.NewAttributeSpanProcessor. If fn is nil, DefaultRequestAttributes is
+// used. To include the request ID, this must run after RequestID.
+func Attributes(fn AttributesFunc) Middleware {
+	if fn == nil {
+		fn = DefaultRequestAttributes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := utility.ContextWithAppendedAttributes(r.Context(), fn(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
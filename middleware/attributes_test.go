@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(utility.NewAttributeSpanProcessor()),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	tracer := provider.Tracer("test")
+
+	t.Run("DefaultAttributesAreAddedToSpans", func(t *testing.T) {
+		handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := tracer.Start(r.Context(), "handler")
+			span.End()
+		}), RequestID(), Attributes(nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/path", nil)
+		req.Header.Set(RequestIDHeader, "request-id")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		require.NotEmpty(t, spans)
+		attrs := spans[len(spans)-1].Attributes()
+		assert.Contains(t, attrs, attribute.String(requestIDAttribute, "request-id"))
+		assert.Contains(t, attrs, attribute.String(requestMethodAttribute, http.MethodGet))
+		assert.Contains(t, attrs, attribute.String(requestPathAttribute, "/path"))
+	})
+	t.Run("CustomAttributesAreAddedToSpans", func(t *testing.T) {
+		custom := attribute.String("custom", "value")
+		handler := Attributes(func(*http.Request) []attribute.KeyValue {
+			return []attribute.KeyValue{custom}
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := tracer.Start(r.Context(), "handler")
+			span.End()
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		spans := recorder.Ended()
+		require.NotEmpty(t, spans)
+		assert.Equal(t, []attribute.KeyValue{custom}, spans[len(spans)-1].Attributes())
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/evergreen-ci/utility"
+)
+
+// BodyLimit limits the size of incoming request bodies to size bytes. Reading
+// past the limit returns an *utility.ErrBodyTooLarge and automatically responds
+// with a 413 (request entity too large), so handlers should stop writing to
+// the response when they see that error. Requests whose declared
+// Content-Length already exceeds the limit are rejected before the handler
+// runs.
+func BodyLimit(size int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > size {
+				err := &utility.ErrBodyTooLarge{Limit: size}
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The limited reader closes the body of the request it wraps, so
+			// it must wrap the original request rather than the one whose body
+			// it replaces.
+			limited := r.Clone(r.Context())
+			limited.Body = utility.NewRequestReaderWithOptions(r, utility.LimitedReaderOptions{
+				Size:           size,
+				ResponseWriter: w,
+			})
+			next.ServeHTTP(w, limited)
+		})
+	}
+}
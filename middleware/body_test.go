@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	var readErr error
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var body []byte
+		body, readErr = io.ReadAll(r.Body)
+		if readErr != nil {
+			return
+		}
+		_, _ = w.Write(body)
+	}), BodyLimit(8))
+
+	t.Run("AllowsBodyWithinLimit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+		require.NoError(t, readErr)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "small", rec.Body.String())
+	})
+	t.Run("RejectsBodyOverLimitWhileReading", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("this body is too large"))
+		// Hide the content length so the limit is only detected while reading.
+		req.ContentLength = -1
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.True(t, utility.MatchesError[*utility.ErrBodyTooLarge](readErr))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+	t.Run("RejectsDeclaredContentLengthOverLimit", func(t *testing.T) {
+		readErr = nil
+		called := false
+		handler := BodyLimit(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("this body is too large")))
+		assert.False(t, called)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+	t.Run("AllowsEmptyBody", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.NoError(t, readErr)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
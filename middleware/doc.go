@@ -0,0 +1,2 @@
+// Package middleware provides composable HTTP server middleware.
+package middleware
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog logs one entry per request to the logger once the handler
+// completes, including the response status, the size of the response and the
+// time taken to serve it.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(w)
+
+			next.ServeHTTP(rec, r)
+
+			logger.InfoContext(r.Context(), "served HTTP request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("request_id", GetRequestID(r.Context())),
+				slog.Int("status", rec.statusCode()),
+				slog.Int64("bytes_written", rec.bytesWritten),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps an http.Handler with additional behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps the handler with the given middleware. The first middleware is
+// the outermost, so it sees the request first and the response last.
+func Chain(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// responseRecorder wraps an http.ResponseWriter to record the status code and
+// the number of bytes written to the response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status       int
+	bytesWritten int64
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec
+	}
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter so that
+// http.ResponseController can access optional interfaces such as
+// http.Flusher.
+func (r *responseRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// wroteHeader returns whether the response status has already been sent.
+func (r *responseRecorder) wroteHeader() bool { return r.status != 0 }
+
+// statusCode returns the status code sent to the client, defaulting to 200 if
+// the handler never wrote one.
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), record("first"), record("second"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"first", "second", "handler"}, order)
+}
+
+func TestResponseRecorder(t *testing.T) {
+	t.Run("DefaultsToOK", func(t *testing.T) {
+		rec := newResponseRecorder(httptest.NewRecorder())
+		assert.False(t, rec.wroteHeader())
+		assert.Equal(t, http.StatusOK, rec.statusCode())
+
+		n, err := rec.Write([]byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		assert.True(t, rec.wroteHeader())
+		assert.Equal(t, http.StatusOK, rec.statusCode())
+		assert.EqualValues(t, 5, rec.bytesWritten)
+	})
+	t.Run("RecordsFirstStatus", func(t *testing.T) {
+		rec := newResponseRecorder(httptest.NewRecorder())
+		rec.WriteHeader(http.StatusTeapot)
+		rec.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(t, http.StatusTeapot, rec.statusCode())
+	})
+	t.Run("ReusesExistingRecorder", func(t *testing.T) {
+		rec := newResponseRecorder(httptest.NewRecorder())
+		assert.Same(t, rec, newResponseRecorder(rec))
+	})
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover recovers from panics in downstream handlers and responds with a 500
+// (internal server error) instead of dropping the connection. If logger is
+// non-nil, the panic value and stack trace are logged. Panics with
+// http.ErrAbortHandler are propagated, since they intentionally abort the
+// response.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newResponseRecorder(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				if logger != nil {
+					logger.ErrorContext(r.Context(), "recovered from panic in HTTP handler",
+						slog.String("panic", fmt.Sprint(recovered)),
+						slog.String("method", r.Method),
+						slog.String("path", r.URL.Path),
+						slog.String("request_id", GetRequestID(r.Context())),
+						slog.String("stack", string(debug.Stack())),
+					)
+				}
+
+				// If the handler already started the response, the status can
+				// no longer be changed.
+				if !rec.wroteHeader() {
+					http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	t.Run("RespondsWithInternalServerError", func(t *testing.T) {
+		var buf bytes.Buffer
+		handler := Recover(slog.New(slog.NewTextHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("something went wrong")
+		}))
+
+		rec := httptest.NewRecorder()
+		assert.NotPanics(t, func() {
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/path", nil))
+		})
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, buf.String(), "something went wrong")
+	})
+	t.Run("KeepsStatusAlreadyWritten", func(t *testing.T) {
+		handler := Recover(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("something went wrong")
+		}))
+
+		rec := httptest.NewRecorder()
+		assert.NotPanics(t, func() {
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	})
+	t.Run("PropagatesAbortHandler", func(t *testing.T) {
+		handler := Recover(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		assert.Panics(t, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+	t.Run("PassesThroughWithoutPanic", func(t *testing.T) {
+		handler := Recover(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
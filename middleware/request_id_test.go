@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var seenID string
+	handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = GetRequestID(r.Context())
+	}))
+
+	t.Run("GeneratesID", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Len(t, seenID, 32)
+		assert.Equal(t, seenID, rec.Header().Get(RequestIDHeader))
+	})
+	t.Run("GeneratesUniqueIDs", func(t *testing.T) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		first := seenID
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NotEqual(t, first, seenID)
+	})
+	t.Run("ReusesIncomingID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, "incoming-id")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, "incoming-id", seenID)
+		assert.Equal(t, "incoming-id", rec.Header().Get(RequestIDHeader))
+	})
+	t.Run("ReplacesOverlyLongIncomingID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, strings.Repeat("a", maxRequestIDLength+1))
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Len(t, seenID, 32)
+	})
+	t.Run("EmptyWithoutMiddleware", func(t *testing.T) {
+		assert.Empty(t, GetRequestID(t.Context()))
+	})
+}
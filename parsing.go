@@ -11,13 +11,24 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
+// readAll reads all the data from r. If r is a size-limited reader whose limit
+// was exceeded, the returned error is an *ErrBodyTooLarge so that callers see
+// the overflow rather than a confusing parse error on truncated data.
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if MatchesError[*ErrBodyTooLarge](err) {
+		return nil, errors.Wrap(err, "body is too large to parse")
+	}
+	return data, errors.WithStack(err)
+}
+
 // ReadYAML provides an alternate interface to yaml.Unmarshal that
 // reads data from an io.ReadCloser.
 func ReadYAML(r io.ReadCloser, target interface{}) error {
 	defer r.Close()
-	data, err := ioutil.ReadAll(r)
+	data, err := readAll(r)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
 	return errors.WithStack(yaml.Unmarshal(data, target))
 }
@@ -25,9 +36,9 @@ func ReadYAML(r io.ReadCloser, target interface{}) error {
 // ReadYAMLStrict is the same as ReadYAML but uses strict unmarshalling.
 func ReadYAMLStrict(r io.ReadCloser, target interface{}) error {
 	defer r.Close()
-	data, err := ioutil.ReadAll(r)
+	data, err := readAll(r)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
 	return errors.WithStack(yaml.UnmarshalStrict(data, target))
 }
@@ -36,9 +47,9 @@ func ReadYAMLStrict(r io.ReadCloser, target interface{}) error {
 // reads data from an io.ReadCloser.
 func ReadJSON(r io.ReadCloser, target interface{}) error {
 	defer r.Close()
-	data, err := ioutil.ReadAll(r)
+	data, err := readAll(r)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
 	return errors.WithStack(json.Unmarshal(data, target))
 }
@@ -3,62 +3,160 @@ package utility
 import (
 	"context"
 	"math"
-	"math/rand"
 	"time"
-
-	"github.com/jpillora/backoff"
-	"github.com/pkg/errors"
 )
 
-func init() {
-	rand.Seed(time.Now().Unix())
-}
-
 // RetryableFunc is any function that takes no parameters and returns an error,
 // and whether or not the operation can be retried. These functions can be used
 // with util.Retry.
 type RetryableFunc func() (canRetry bool, err error)
 
+// RetryableValueFunc is a function that produces a result, and whether or not
+// the operation can be retried if it errors. It receives a context scoped to
+// the current attempt, which is canceled once the attempt returns, and the
+// attempt number, starting at 1. These functions can be used with RetryValue.
+type RetryableValueFunc[T any] func(ctx context.Context, attempt int) (result T, canRetry bool, err error)
+
 // Retry provides a mechanism to retry an operation with exponential backoff
-// and jitter.
+// and jitter. An operation can return errors marked with Permanent or
+// RetryAfter to control retries from the error alone.
 func Retry(ctx context.Context, op RetryableFunc, opts RetryOptions) error {
+	_, err := RetryValue(ctx, func(context.Context, int) (struct{}, bool, error) {
+		canRetry, err := op()
+		return struct{}{}, canRetry, err
+	}, opts)
+	return err
+}
+
+// RetryValue is the same as Retry but returns the result of the first
+// successful attempt. If the operation does not succeed, the result of the
+// last attempt is returned along with a *RetryError.
+func RetryValue[T any](ctx context.Context, op RetryableValueFunc[T], opts RetryOptions) (T, error) {
 	backoff := getBackoff(opts)
-	timer := time.NewTimer(0)
+	clock := getClock(opts.Clock)
+	timer := clock.NewTimer(0)
 	defer timer.Stop()
-	var attempt int
+	var (
+		result   T
+		deadline time.Time
+		attempts []RetryAttempt
+	)
+	if opts.MaxElapsedTime > 0 {
+		deadline = clock.Now().Add(opts.MaxElapsedTime)
+	}
+	ctx, telemetry := startRetryTelemetry(ctx, opts)
+
+	giveUp := func(reason RetryStopReason, err error) (T, error) {
+		retryErr := &RetryError{
+			Reason:   reason,
+			Attempts: attempts,
+			Err:      err,
+
+			maxElapsedTime: opts.MaxElapsedTime,
+		}
+		telemetry.end(ctx, len(attempts), retryErr)
+		if opts.OnGiveUp != nil {
+			opts.OnGiveUp(len(attempts), retryErr)
+		}
+		return result, retryErr
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return errors.Wrapf(ctx.Err(), "context canceled after %d attempts", attempt)
-		case <-timer.C:
-			shouldRetry, err := op()
+			return giveUp(RetryStopContextDone, ctx.Err())
+		case <-timer.C():
+			attemptNum := len(attempts) + 1
+			attemptStart := clock.Now()
+			var (
+				shouldRetry bool
+				err         error
+			)
+			result, shouldRetry, err = runAttempt(ctx, clock, op, attemptNum, opts.AttemptTimeout, deadline)
+			duration := clock.Now().Sub(attemptStart)
 			if err == nil {
-				return nil
+				telemetry.attempt(attemptNum, nil, duration, 0)
+				telemetry.end(ctx, attemptNum, nil)
+				if opts.OnSuccess != nil {
+					opts.OnSuccess(attemptNum)
+				}
+				return result, nil
 			}
+			attempts = append(attempts, RetryAttempt{
+				Number:   attemptNum,
+				Err:      err,
+				Duration: duration,
+			})
+
+			if !deadline.IsZero() && !clock.Now().Before(deadline) {
+				// The attempt may have failed only because its context was
+				// canceled at the deadline.
+				telemetry.attempt(attemptNum, err, duration, 0)
+				return giveUp(RetryStopMaxElapsedTime, err)
+			}
+			shouldRetry, retryAfter, hasRetryAfter := classifyRetry(err, shouldRetry, opts.ClassifyError)
 			if !shouldRetry {
-				return err
+				telemetry.attempt(attemptNum, err, duration, 0)
+				return giveUp(RetryStopNonRetryable, err)
+			}
+			if attemptNum == opts.MaxAttempts {
+				telemetry.attempt(attemptNum, err, duration, 0)
+				return giveUp(RetryStopMaxAttempts, err)
 			}
 
-			attempt++
-			if attempt == opts.MaxAttempts {
-				return errors.Wrapf(err, "after %d attempts, operation failed", opts.MaxAttempts)
+			delay := backoff.Duration()
+			if hasRetryAfter {
+				delay = min(retryAfter, backoff.Max)
 			}
-			timer.Reset(backoff.Duration())
+			if !deadline.IsZero() && clock.Now().Add(delay).After(deadline) {
+				telemetry.attempt(attemptNum, err, duration, 0)
+				return giveUp(RetryStopMaxElapsedTime, err)
+			}
+			telemetry.attempt(attemptNum, err, duration, delay)
+
+			if opts.OnRetry != nil {
+				opts.OnRetry(attemptNum, err, delay)
+			}
+			timer.Reset(delay)
 		}
 	}
 }
 
-// backoffFactor is the exponential backoff factor.
+// runAttempt runs a single attempt of the operation with a context that is
+// canceled as soon as the attempt finishes. The attempt context is also
+// canceled once the attempt timeout or the overall deadline passes, whichever
+// is first, as measured by the clock.
+func runAttempt[T any](ctx context.Context, clock Clock, op RetryableValueFunc[T], attempt int, timeout time.Duration, deadline time.Time) (T, bool, error) {
+	if timeout > 0 {
+		attemptDeadline := clock.Now().Add(timeout)
+		if deadline.IsZero() || attemptDeadline.Before(deadline) {
+			deadline = attemptDeadline
+		}
+	}
+
+	var cancel context.CancelFunc
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = contextWithClockDeadline(ctx, clock, deadline)
+	}
+	defer cancel()
+
+	return op(ctx, attempt)
+}
+
+// backoffFactor is the default exponential backoff factor.
 const backoffFactor = 2
 
-func getBackoff(opts RetryOptions) *backoff.Backoff {
+// defaultMinDelay is the default minimum delay between attempts.
+const defaultMinDelay = 100 * time.Millisecond
+
+func getBackoff(opts RetryOptions) *backoff {
 	opts.Validate()
-	return &backoff.Backoff{
-		Min:    opts.MinDelay,
-		Max:    opts.MaxDelay,
-		Factor: float64(backoffFactor),
-		Jitter: true,
+	return &backoff{
+		strategy: opts.Backoff,
+		Min:      opts.MinDelay,
+		Max:      opts.MaxDelay,
 	}
 }
 
@@ -74,6 +172,52 @@ type RetryOptions struct {
 	// MaxDelay is the maximum delay between operation attempts. By default, it
 	// is (MinDelay * 2^MaxAttempts).
 	MaxDelay time.Duration
+	// Backoff is the strategy that computes the delay between attempts, which
+	// is always kept between MinDelay and MaxDelay. By default, it is
+	// exponential backoff with a factor of 2 and jitter.
+	Backoff BackoffStrategy
+	// AttemptTimeout, if set, is the maximum duration of a single attempt.
+	// Each attempt receives a context that is canceled once the timeout
+	// passes. Operations that do not take a context, such as a
+	// RetryableFunc, cannot be interrupted.
+	AttemptTimeout time.Duration
+	// MaxElapsedTime, if set, is the total time budget for the operation,
+	// including every attempt and the delays between them. No attempt is made
+	// if it would start after the budget is spent, and attempt contexts are
+	// canceled once it is spent.
+	MaxElapsedTime time.Duration
+	// ClassifyError, if set, decides whether a failed attempt can be retried
+	// based on its error, overriding the value returned by the operation.
+	// Errors marked with Permanent or RetryAfter are never passed to it.
+	ClassifyError ErrorClassifier
+	// Clock, if set, is used to wait between attempts and to measure timeouts
+	// and elapsed time. By default, it is the system clock. When a clock
+	// other than RealClock times out an attempt, the attempt context's error
+	// is context.Canceled and its cause is context.DeadlineExceeded.
+	Clock Clock
+
+	// OnRetry, if set, is called after each failed attempt that will be
+	// retried, with the attempt number, its error and the delay before the
+	// next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+	// OnGiveUp, if set, is called once when the operation fails for good, with
+	// the number of attempts made and the *RetryError that is returned.
+	OnGiveUp func(attempts int, err error)
+	// OnSuccess, if set, is called once when an attempt succeeds, with the
+	// attempt number.
+	OnSuccess func(attempt int)
+
+	// Instrument enables OpenTelemetry instrumentation of the operation. A
+	// span covers the whole operation and has an event for each attempt with
+	// its error and the delay before the next attempt. The number of attempts
+	// is recorded in the evergreen.retry.attempts histogram and operations
+	// that fail are counted in the evergreen.retry.give_ups counter. The
+	// span carries the attributes added with ContextWithAttributes, but the
+	// metrics only carry the name and outcome of the operation.
+	Instrument bool
+	// Name identifies the operation in its span and metrics when Instrument
+	// is set. By default, it is "retry".
+	Name string
 }
 
 // Validate sets defaults for unspecified or invalid options.
@@ -81,17 +225,21 @@ type RetryOptions struct {
 // It will set min to 100ms if not set.
 // It will set max to (min * 2^attempts) if not set.
 // It will set attempts to 1 if not set.
+// It will set the backoff strategy to exponential backoff if not set.
 func (o *RetryOptions) Validate() {
 	if o.MaxAttempts <= 0 {
 		o.MaxAttempts = 1
 	}
 
-	const floor = 100 * time.Millisecond
-	if o.MinDelay < floor {
-		o.MinDelay = floor
+	if o.MinDelay <= 0 {
+		o.MinDelay = defaultMinDelay
 	}
 
 	if o.MaxDelay <= 0 {
-		o.MaxDelay = time.Duration(float64(o.MinDelay) * math.Pow(backoffFactor, float64(o.MaxAttempts)))
+		o.MaxDelay = safeDuration(float64(o.MinDelay) * math.Pow(backoffFactor, float64(o.MaxAttempts)))
+	}
+
+	if o.Backoff == nil {
+		o.Backoff = defaultBackoffStrategy
 	}
 }
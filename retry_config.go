@@ -0,0 +1,392 @@
+package utility
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the backoff strategies that can be configured in serialized retry
+// options.
+const (
+	BackoffNameConstant           = "constant"
+	BackoffNameLinear             = "linear"
+	BackoffNameExponential        = "exponential"
+	BackoffNameDecorrelatedJitter = "decorrelated_jitter"
+	BackoffNameFibonacci          = "fibonacci"
+)
+
+// RetryOptionsConfig is the serialized form of RetryOptions, which can be
+// read from YAML or JSON configuration and converted with ToRetryOptions.
+// Durations are strings such as "250ms" or "5s". Fields that are omitted
+// keep their current value, so defaults can be set before decoding, and
+// fields that are empty use the RetryOptions defaults. Hooks, the error
+// classifier and the clock can only be set in code.
+type RetryOptionsConfig struct {
+	MaxAttempts    int            `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	MinDelay       string         `json:"min_delay,omitempty" yaml:"min_delay,omitempty"`
+	MaxDelay       string         `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`
+	Backoff        *BackoffConfig `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	AttemptTimeout string         `json:"attempt_timeout,omitempty" yaml:"attempt_timeout,omitempty"`
+	MaxElapsedTime string         `json:"max_elapsed_time,omitempty" yaml:"max_elapsed_time,omitempty"`
+	Instrument     bool           `json:"instrument,omitempty" yaml:"instrument,omitempty"`
+	Name           string         `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// ToRetryOptions validates the configuration and returns the retry options
+// it describes.
+func (c RetryOptionsConfig) ToRetryOptions() (RetryOptions, error) {
+	opts, err := c.toRetryOptions()
+	return opts, errors.Wrap(err, "invalid retry options")
+}
+
+func (c RetryOptionsConfig) toRetryOptions() (RetryOptions, error) {
+	var opts RetryOptions
+	if c.MaxAttempts < 0 {
+		return opts, errors.Errorf("max_attempts: must not be negative, but is %d", c.MaxAttempts)
+	}
+	opts.MaxAttempts = c.MaxAttempts
+
+	for _, d := range []struct {
+		field string
+		value string
+		dst   *time.Duration
+	}{
+		{field: "min_delay", value: c.MinDelay, dst: &opts.MinDelay},
+		{field: "max_delay", value: c.MaxDelay, dst: &opts.MaxDelay},
+		{field: "attempt_timeout", value: c.AttemptTimeout, dst: &opts.AttemptTimeout},
+		{field: "max_elapsed_time", value: c.MaxElapsedTime, dst: &opts.MaxElapsedTime},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := parseConfigDuration(d.field, d.value)
+		if err != nil {
+			return RetryOptions{}, err
+		}
+		*d.dst = duration
+	}
+
+	if c.Backoff != nil {
+		strategy, err := c.Backoff.ToBackoffStrategy()
+		if err != nil {
+			return RetryOptions{}, err
+		}
+		opts.Backoff = strategy
+	}
+	opts.Instrument = c.Instrument
+	opts.Name = c.Name
+
+	if opts.MinDelay > 0 && opts.MaxDelay > 0 && opts.MinDelay > opts.MaxDelay {
+		return RetryOptions{}, errors.Errorf("min_delay (%s) must not exceed max_delay (%s)", opts.MinDelay, opts.MaxDelay)
+	}
+
+	return opts, nil
+}
+
+// RetryRequestOptionsConfig is the serialized form of RetryRequestOptions,
+// which can be read from YAML or JSON configuration and converted with
+// ToRetryRequestOptions. It accepts the same fields as RetryOptionsConfig, as
+// well as retry_on_invalid_body and retry_on_413.
+type RetryRequestOptionsConfig struct {
+	RetryOptionsConfig `yaml:",inline"`
+	RetryOnInvalidBody bool `json:"retry_on_invalid_body,omitempty" yaml:"retry_on_invalid_body,omitempty"`
+	RetryOn413         bool `json:"retry_on_413,omitempty" yaml:"retry_on_413,omitempty"`
+}
+
+// ToRetryRequestOptions validates the configuration and returns the request
+// retry options it describes.
+func (c RetryRequestOptionsConfig) ToRetryRequestOptions() (RetryRequestOptions, error) {
+	opts, err := c.RetryOptionsConfig.toRetryOptions()
+	if err != nil {
+		return RetryRequestOptions{}, errors.Wrap(err, "invalid request retry options")
+	}
+	return RetryRequestOptions{
+		RetryOptions:       opts,
+		RetryOnInvalidBody: c.RetryOnInvalidBody,
+		RetryOn413:         c.RetryOn413,
+	}, nil
+}
+
+// httpRetryConfig is the serialized form of HTTPRetryConfiguration. Fields
+// that are omitted leave the corresponding option unchanged.
+type httpRetryConfig struct {
+	MaxDelay        any       `json:"max_delay" yaml:"max_delay"`
+	BaseDelay       any       `json:"base_delay" yaml:"base_delay"`
+	MaxRetries      *int      `json:"max_retries" yaml:"max_retries"`
+	TemporaryErrors *bool     `json:"temporary_errors" yaml:"temporary_errors"`
+	Methods         *[]string `json:"methods" yaml:"methods"`
+	Statuses        *[]any    `json:"statuses" yaml:"statuses"`
+	ErrorStrings    *[]string `json:"error_strings" yaml:"error_strings"`
+}
+
+// UnmarshalJSON decodes the configuration from JSON. See UnmarshalYAML for
+// the format.
+func (c *HTTPRetryConfiguration) UnmarshalJSON(data []byte) error {
+	var conf httpRetryConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return errors.Wrap(err, "invalid HTTP retry configuration")
+	}
+	return errors.Wrap(conf.apply(c), "invalid HTTP retry configuration")
+}
+
+// UnmarshalYAML decodes the configuration from YAML. The fields are
+// max_delay, base_delay, max_retries, temporary_errors, methods, statuses and
+// error_strings. Durations are strings such as "250ms" or "5s". Statuses may
+// be status codes, ranges of status codes such as "500-504", or classes of
+// status codes such as "5xx". Omitted fields leave the configuration
+// unchanged, so it can be decoded over NewDefaultHTTPRetryConf. Errors can
+// only be set in code.
+func (c *HTTPRetryConfiguration) UnmarshalYAML(unmarshal func(any) error) error {
+	var conf httpRetryConfig
+	if err := unmarshal(&conf); err != nil {
+		return errors.Wrap(err, "invalid HTTP retry configuration")
+	}
+	return errors.Wrap(conf.apply(c), "invalid HTTP retry configuration")
+}
+
+// apply validates the configuration and sets the HTTP retry configuration
+// from it. The HTTP retry configuration is only modified if the whole
+// configuration is valid.
+func (c *httpRetryConfig) apply(conf *HTTPRetryConfiguration) error {
+	out := *conf
+	for _, d := range []struct {
+		field string
+		value any
+		dst   *time.Duration
+	}{
+		{field: "max_delay", value: c.MaxDelay, dst: &out.MaxDelay},
+		{field: "base_delay", value: c.BaseDelay, dst: &out.BaseDelay},
+	} {
+		if d.value == nil {
+			continue
+		}
+		duration, err := parseConfigDuration(d.field, d.value)
+		if err != nil {
+			return err
+		}
+		*d.dst = duration
+	}
+
+	if c.MaxRetries != nil {
+		if *c.MaxRetries < 0 {
+			return errors.Errorf("max_retries: must not be negative, but is %d", *c.MaxRetries)
+		}
+		out.MaxRetries = *c.MaxRetries
+	}
+	if c.TemporaryErrors != nil {
+		out.TemporaryErrors = *c.TemporaryErrors
+	}
+	if c.Methods != nil {
+		methods := make([]string, 0, len(*c.Methods))
+		for i, method := range *c.Methods {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if method == "" {
+				return errors.Errorf("methods[%d]: must not be empty", i)
+			}
+			methods = append(methods, method)
+		}
+		out.Methods = methods
+	}
+	if c.Statuses != nil {
+		statuses, err := parseStatusRanges(*c.Statuses)
+		if err != nil {
+			return err
+		}
+		out.Statuses = statuses
+	}
+	if c.ErrorStrings != nil {
+		out.ErrorStrings = *c.ErrorStrings
+	}
+
+	if out.BaseDelay > 0 && out.MaxDelay > 0 && out.BaseDelay > out.MaxDelay {
+		return errors.Errorf("base_delay (%s) must not exceed max_delay (%s)", out.BaseDelay, out.MaxDelay)
+	}
+
+	*conf = out
+	return nil
+}
+
+// BackoffConfig is the serialized form of a BackoffStrategy. It is decoded
+// from either the name of a strategy ("constant", "linear", "exponential",
+// "decorrelated_jitter" or "fibonacci") or an object with a strategy name and
+// its parameters: increment for linear, and factor and jitter for
+// exponential.
+type BackoffConfig struct {
+	Strategy  string   `json:"strategy" yaml:"strategy"`
+	Increment string   `json:"increment,omitempty" yaml:"increment,omitempty"`
+	Factor    *float64 `json:"factor,omitempty" yaml:"factor,omitempty"`
+	Jitter    *bool    `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// backoffSpec has the fields of a BackoffConfig without its decoding
+// methods, so that it can be decoded as an object.
+type backoffSpec BackoffConfig
+
+func (b *BackoffConfig) UnmarshalJSON(data []byte) error {
+	var spec backoffSpec
+	if err := json.Unmarshal(data, &spec.Strategy); err != nil {
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return errors.Wrap(err, "backoff: must be a strategy name or an object with a strategy")
+		}
+	}
+	*b = BackoffConfig(spec)
+	return nil
+}
+
+func (b *BackoffConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var spec backoffSpec
+	if err := unmarshal(&spec.Strategy); err != nil {
+		if err := unmarshal(&spec); err != nil {
+			return errors.Wrap(err, "backoff: must be a strategy name or an object with a strategy")
+		}
+	}
+	*b = BackoffConfig(spec)
+	return nil
+}
+
+// ToBackoffStrategy validates the configuration and returns the strategy it
+// describes.
+func (s BackoffConfig) ToBackoffStrategy() (BackoffStrategy, error) {
+	if s.Increment != "" && s.Strategy != BackoffNameLinear {
+		return nil, errors.Errorf("backoff: increment is only valid for the '%s' strategy", BackoffNameLinear)
+	}
+	if (s.Factor != nil || s.Jitter != nil) && s.Strategy != BackoffNameExponential {
+		return nil, errors.Errorf("backoff: factor and jitter are only valid for the '%s' strategy", BackoffNameExponential)
+	}
+
+	switch s.Strategy {
+	case BackoffNameConstant:
+		return ConstantBackoff{}, nil
+	case BackoffNameLinear:
+		var b LinearBackoff
+		if s.Increment != "" {
+			increment, err := parseConfigDuration("backoff.increment", s.Increment)
+			if err != nil {
+				return nil, err
+			}
+			b.Increment = increment
+		}
+		return b, nil
+	case BackoffNameExponential:
+		b := ExponentialBackoff{Factor: backoffFactor, Jitter: true}
+		if s.Factor != nil {
+			if *s.Factor < 1 {
+				return nil, errors.Errorf("backoff.factor: must be at least 1, but is %g", *s.Factor)
+			}
+			b.Factor = *s.Factor
+		}
+		if s.Jitter != nil {
+			b.Jitter = *s.Jitter
+		}
+		return b, nil
+	case BackoffNameDecorrelatedJitter:
+		return DecorrelatedJitterBackoff{}, nil
+	case BackoffNameFibonacci:
+		return FibonacciBackoff{}, nil
+	case "":
+		return nil, errors.New("backoff: must specify a strategy")
+	default:
+		return nil, errors.Errorf("backoff: unknown strategy '%s' (must be one of %s)", s.Strategy, strings.Join([]string{
+			BackoffNameConstant,
+			BackoffNameLinear,
+			BackoffNameExponential,
+			BackoffNameDecorrelatedJitter,
+			BackoffNameFibonacci,
+		}, ", "))
+	}
+}
+
+// parseConfigDuration parses a duration string such as "250ms" from the named
+// configuration field.
+func parseConfigDuration(field string, value any) (time.Duration, error) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, errors.Errorf("%s: must be a duration string such as '250ms' or '5s', but is %v", field, value)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("%s: invalid duration '%s'", field, s)
+	}
+	if d < 0 {
+		return 0, errors.Errorf("%s: must not be negative, but is '%s'", field, s)
+	}
+	return d, nil
+}
+
+// parseStatusRanges expands a list of status codes, status code ranges such
+// as "500-504" and status code classes such as "5xx" into the status codes
+// they contain, without duplicates.
+func parseStatusRanges(specs []any) ([]int, error) {
+	var statuses []int
+	seen := map[int]bool{}
+	for i, spec := range specs {
+		field := fmt.Sprintf("statuses[%d]", i)
+		low, high, err := parseStatusRange(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, field)
+		}
+		for status := low; status <= high; status++ {
+			if !seen[status] {
+				seen[status] = true
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return statuses, nil
+}
+
+func parseStatusRange(spec any) (low, high int, err error) {
+	var s string
+	switch v := spec.(type) {
+	case int:
+		s = strconv.Itoa(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return 0, 0, errors.Errorf("must be a status code, range or class, but is %v", spec)
+	}
+
+	if len(s) == 3 && strings.EqualFold(s[1:], "xx") {
+		class, err := strconv.Atoi(s[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, errors.Errorf("invalid status class '%s'", s)
+		}
+		return class * 100, class*100 + 99, nil
+	}
+
+	if lowStr, highStr, ok := strings.Cut(s, "-"); ok {
+		low, lowErr := parseStatusCode(lowStr)
+		high, highErr := parseStatusCode(highStr)
+		if lowErr != nil || highErr != nil {
+			return 0, 0, errors.Errorf("invalid status range '%s'", s)
+		}
+		if low > high {
+			return 0, 0, errors.Errorf("invalid status range '%s': start is after end", s)
+		}
+		return low, high, nil
+	}
+
+	status, err := parseStatusCode(s)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid status code '%s'", s)
+	}
+	return status, status, nil
+}
+
+func parseStatusCode(s string) (int, error) {
+	status, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if status < 100 || status > 599 {
+		return 0, errors.Errorf("status code %d is out of range", status)
+	}
+	return status, nil
+}
@@ -0,0 +1,287 @@
+package utility
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRetryOptionsConfig(t *testing.T) {
+	t.Run("ReadYAMLFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "retry.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+max_attempts: 5
+min_delay: 250ms
+max_delay: 5s
+attempt_timeout: 1s
+max_elapsed_time: 1m
+backoff:
+  strategy: exponential
+  factor: 3
+  jitter: false
+`), 0600))
+
+		var conf RetryOptionsConfig
+		require.NoError(t, ReadYAMLFile(path, &conf))
+		opts, err := conf.ToRetryOptions()
+		require.NoError(t, err)
+		assert.Equal(t, 5, opts.MaxAttempts)
+		assert.Equal(t, 250*time.Millisecond, opts.MinDelay)
+		assert.Equal(t, 5*time.Second, opts.MaxDelay)
+		assert.Equal(t, time.Second, opts.AttemptTimeout)
+		assert.Equal(t, time.Minute, opts.MaxElapsedTime)
+		assert.Equal(t, ExponentialBackoff{Factor: 3}, opts.Backoff)
+	})
+	t.Run("ReadJSONFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "retry.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"max_attempts": 3,
+			"min_delay": "100ms",
+			"backoff": {"strategy": "linear", "increment": "50ms"}
+		}`), 0600))
+
+		var conf RetryOptionsConfig
+		require.NoError(t, ReadJSONFile(path, &conf))
+		opts, err := conf.ToRetryOptions()
+		require.NoError(t, err)
+		assert.Equal(t, 3, opts.MaxAttempts)
+		assert.Equal(t, 100*time.Millisecond, opts.MinDelay)
+		assert.Equal(t, LinearBackoff{Increment: 50 * time.Millisecond}, opts.Backoff)
+	})
+	t.Run("NamedBackoffStrategies", func(t *testing.T) {
+		for name, expected := range map[string]BackoffStrategy{
+			BackoffNameConstant:           ConstantBackoff{},
+			BackoffNameLinear:             LinearBackoff{},
+			BackoffNameExponential:        ExponentialBackoff{Factor: backoffFactor, Jitter: true},
+			BackoffNameDecorrelatedJitter: DecorrelatedJitterBackoff{},
+			BackoffNameFibonacci:          FibonacciBackoff{},
+		} {
+			t.Run(name, func(t *testing.T) {
+				var conf RetryOptionsConfig
+				require.NoError(t, yaml.Unmarshal([]byte("backoff: "+name), &conf))
+				opts, err := conf.ToRetryOptions()
+				require.NoError(t, err)
+				assert.Equal(t, expected, opts.Backoff)
+
+				conf = RetryOptionsConfig{}
+				require.NoError(t, json.Unmarshal([]byte(`{"backoff": "`+name+`"}`), &conf))
+				opts, err = conf.ToRetryOptions()
+				require.NoError(t, err)
+				assert.Equal(t, expected, opts.Backoff)
+			})
+		}
+	})
+	t.Run("OmittedFieldsAreUnchanged", func(t *testing.T) {
+		conf := RetryOptionsConfig{MaxAttempts: 10, MinDelay: "1s", AttemptTimeout: "1m"}
+		require.NoError(t, yaml.Unmarshal([]byte("max_attempts: 2"), &conf))
+		opts, err := conf.ToRetryOptions()
+		require.NoError(t, err)
+		assert.Equal(t, 2, opts.MaxAttempts)
+		assert.Equal(t, time.Second, opts.MinDelay)
+		assert.Equal(t, time.Minute, opts.AttemptTimeout)
+	})
+	t.Run("EmbeddedInConfiguration", func(t *testing.T) {
+		type serviceConfig struct {
+			RetryOptionsConfig `yaml:",inline"`
+			URL                string `json:"url" yaml:"url"`
+		}
+		var conf serviceConfig
+		require.NoError(t, yaml.Unmarshal([]byte("url: https://example.com\nmax_attempts: 3"), &conf))
+		assert.Equal(t, "https://example.com", conf.URL)
+		assert.Equal(t, 3, conf.MaxAttempts)
+
+		conf = serviceConfig{}
+		require.NoError(t, json.Unmarshal([]byte(`{"url": "https://example.com", "max_attempts": 3}`), &conf))
+		assert.Equal(t, "https://example.com", conf.URL)
+		assert.Equal(t, 3, conf.MaxAttempts)
+	})
+	t.Run("RetryRequestOptions", func(t *testing.T) {
+		var conf RetryRequestOptionsConfig
+		require.NoError(t, yaml.Unmarshal([]byte(`
+max_attempts: 4
+min_delay: 1s
+retry_on_413: true
+`), &conf))
+		opts, err := conf.ToRetryRequestOptions()
+		require.NoError(t, err)
+		assert.Equal(t, 4, opts.MaxAttempts)
+		assert.Equal(t, time.Second, opts.MinDelay)
+		assert.True(t, opts.RetryOn413)
+		assert.False(t, opts.RetryOnInvalidBody)
+
+		conf = RetryRequestOptionsConfig{}
+		require.NoError(t, json.Unmarshal([]byte(`{"max_attempts": 4, "retry_on_invalid_body": true}`), &conf))
+		opts, err = conf.ToRetryRequestOptions()
+		require.NoError(t, err)
+		assert.Equal(t, 4, opts.MaxAttempts)
+		assert.True(t, opts.RetryOnInvalidBody)
+
+		conf = RetryRequestOptionsConfig{}
+		require.NoError(t, yaml.Unmarshal([]byte("min_delay: soon"), &conf))
+		_, err = conf.ToRetryRequestOptions()
+		assert.ErrorContains(t, err, "min_delay: invalid duration 'soon'")
+	})
+	t.Run("InvalidConfigurations", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			yaml     string
+			expected string
+		}{
+			"InvalidDuration": {
+				yaml:     "min_delay: soon",
+				expected: "min_delay: invalid duration 'soon'",
+			},
+			"DurationWithoutUnit": {
+				yaml:     "max_delay: 5",
+				expected: "max_delay: invalid duration '5'",
+			},
+			"NegativeDuration": {
+				yaml:     "attempt_timeout: -1s",
+				expected: "attempt_timeout: must not be negative",
+			},
+			"NegativeMaxAttempts": {
+				yaml:     "max_attempts: -1",
+				expected: "max_attempts: must not be negative",
+			},
+			"MinDelayExceedsMaxDelay": {
+				yaml:     "min_delay: 5s\nmax_delay: 1s",
+				expected: "min_delay (5s) must not exceed max_delay (1s)",
+			},
+			"UnknownBackoffStrategy": {
+				yaml:     "backoff: quadratic",
+				expected: "backoff: unknown strategy 'quadratic'",
+			},
+			"BackoffWithoutStrategy": {
+				yaml:     "backoff:\n  factor: 2",
+				expected: "factor and jitter are only valid for the 'exponential' strategy",
+			},
+			"MissingBackoffStrategy": {
+				yaml:     "backoff: {}",
+				expected: "backoff: must specify a strategy",
+			},
+			"InvalidBackoffParameter": {
+				yaml:     "backoff:\n  strategy: constant\n  increment: 1s",
+				expected: "backoff: increment is only valid for the 'linear' strategy",
+			},
+			"InvalidBackoffFactor": {
+				yaml:     "backoff:\n  strategy: exponential\n  factor: 0.5",
+				expected: "backoff.factor: must be at least 1",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				var conf RetryOptionsConfig
+				require.NoError(t, yaml.Unmarshal([]byte(test.yaml), &conf))
+				opts, err := conf.ToRetryOptions()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expected)
+				assert.Zero(t, opts)
+			})
+		}
+	})
+	t.Run("InvalidJSONReportsField", func(t *testing.T) {
+		var conf RetryOptionsConfig
+		require.NoError(t, json.Unmarshal([]byte(`{"max_delay": "1 minute"}`), &conf))
+		_, err := conf.ToRetryOptions()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "max_delay: invalid duration '1 minute'")
+
+		err = json.Unmarshal([]byte(`{"max_delay": 5}`), &conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "max_delay")
+	})
+}
+
+func TestHTTPRetryConfigurationConfig(t *testing.T) {
+	t.Run("ReadYAMLFileOverDefaults", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http_retry.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+base_delay: 100ms
+max_retries: 3
+methods: [get, POST]
+statuses: [429, "500-502", "504"]
+`), 0600))
+
+		conf := NewDefaultHTTPRetryConf()
+		require.NoError(t, ReadYAMLFile(path, &conf))
+		assert.Equal(t, 100*time.Millisecond, conf.BaseDelay)
+		assert.Equal(t, 5*time.Second, conf.MaxDelay)
+		assert.Equal(t, 3, conf.MaxRetries)
+		assert.True(t, conf.TemporaryErrors)
+		assert.Equal(t, []string{http.MethodGet, http.MethodPost}, conf.Methods)
+		assert.Equal(t, []int{429, 500, 501, 502, 504}, conf.Statuses)
+	})
+	t.Run("ReadJSONFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http_retry.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"max_delay": "10s",
+			"temporary_errors": false,
+			"statuses": ["5xx", 408],
+			"error_strings": ["connection reset by peer"]
+		}`), 0600))
+
+		var conf HTTPRetryConfiguration
+		require.NoError(t, ReadJSONFile(path, &conf))
+		assert.Equal(t, 10*time.Second, conf.MaxDelay)
+		assert.False(t, conf.TemporaryErrors)
+		require.Len(t, conf.Statuses, 101)
+		assert.Equal(t, 500, conf.Statuses[0])
+		assert.Equal(t, 599, conf.Statuses[99])
+		assert.Equal(t, 408, conf.Statuses[100])
+		assert.Equal(t, []string{"connection reset by peer"}, conf.ErrorStrings)
+	})
+	t.Run("OverlappingStatusesAreDeduplicated", func(t *testing.T) {
+		var conf HTTPRetryConfiguration
+		require.NoError(t, yaml.Unmarshal([]byte(`statuses: ["502-503", 503, "5XX"]`), &conf))
+		assert.Len(t, conf.Statuses, 100)
+		assert.Equal(t, []int{502, 503, 500, 501}, conf.Statuses[:4])
+	})
+	t.Run("InvalidConfigurations", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			yaml     string
+			expected string
+		}{
+			"InvalidStatusClass": {
+				yaml:     `statuses: [500, "6xx"]`,
+				expected: "statuses[1]: invalid status class '6xx'",
+			},
+			"InvalidStatusCode": {
+				yaml:     `statuses: [99]`,
+				expected: "statuses[0]: invalid status code '99'",
+			},
+			"InvalidStatusRange": {
+				yaml:     `statuses: ["500-abc"]`,
+				expected: "statuses[0]: invalid status range '500-abc'",
+			},
+			"BackwardStatusRange": {
+				yaml:     `statuses: ["504-500"]`,
+				expected: "statuses[0]: invalid status range '504-500': start is after end",
+			},
+			"EmptyMethod": {
+				yaml:     `methods: [GET, " "]`,
+				expected: "methods[1]: must not be empty",
+			},
+			"NegativeMaxRetries": {
+				yaml:     "max_retries: -2",
+				expected: "max_retries: must not be negative",
+			},
+			"BaseDelayExceedsMaxDelay": {
+				yaml:     "base_delay: 10s",
+				expected: "base_delay (10s) must not exceed max_delay (5s)",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				conf := NewDefaultHTTPRetryConf()
+				err := yaml.Unmarshal([]byte(test.yaml), &conf)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expected)
+				assert.Equal(t, NewDefaultHTTPRetryConf(), conf, "invalid configuration should not modify the options")
+			})
+		}
+	})
+}
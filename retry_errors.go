@@ -0,0 +1,152 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryStopReason describes why a retried operation stopped without
+// succeeding.
+type RetryStopReason string
+
+const (
+	// RetryStopNonRetryable means the last attempt failed with an error that
+	// cannot be retried.
+	RetryStopNonRetryable RetryStopReason = "non-retryable error"
+	// RetryStopMaxAttempts means the maximum number of attempts was reached.
+	RetryStopMaxAttempts RetryStopReason = "maximum attempts reached"
+	// RetryStopMaxElapsedTime means the maximum elapsed time would be
+	// exceeded by waiting for another attempt.
+	RetryStopMaxElapsedTime RetryStopReason = "maximum elapsed time exceeded"
+	// RetryStopContextDone means the context was done before the operation
+	// succeeded.
+	RetryStopContextDone RetryStopReason = "context done"
+)
+
+// RetryAttempt records a single failed attempt of a retried operation.
+type RetryAttempt struct {
+	// Number is the attempt number, starting at 1.
+	Number int
+	// Err is the error the attempt failed with.
+	Err error
+	// Duration is how long the attempt took.
+	Duration time.Duration
+}
+
+// RetryError is returned when a retried operation does not succeed. It
+// records every failed attempt, and unwraps to the error that ended the
+// operation, which is either the last attempt's error or, if the context was
+// done, the context's error.
+type RetryError struct {
+	// Reason is why the operation stopped.
+	Reason RetryStopReason
+	// Attempts are the failed attempts, in order.
+	Attempts []RetryAttempt
+	// Err is the error that ended the operation.
+	Err error
+
+	maxElapsedTime time.Duration
+}
+
+func (e *RetryError) Error() string {
+	switch e.Reason {
+	case RetryStopMaxAttempts:
+		return fmt.Sprintf("after %d attempts, operation failed: %s", len(e.Attempts), e.Err)
+	case RetryStopMaxElapsedTime:
+		return fmt.Sprintf("after %d attempts, operation exceeded the maximum elapsed time of %s: %s", len(e.Attempts), e.maxElapsedTime, e.Err)
+	case RetryStopContextDone:
+		return fmt.Sprintf("context canceled after %d attempts: %s", len(e.Attempts), e.Err)
+	default:
+		return e.Err.Error()
+	}
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+func (e *RetryError) Cause() error  { return e.Err }
+
+// LastAttempt returns the most recent failed attempt, if any.
+func (e *RetryError) LastAttempt() (RetryAttempt, bool) {
+	if len(e.Attempts) == 0 {
+		return RetryAttempt{}, false
+	}
+	return e.Attempts[len(e.Attempts)-1], true
+}
+
+// ErrorClassifier decides whether an operation that failed with err can be
+// retried.
+type ErrorClassifier func(err error) (canRetry bool)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+func (e *permanentError) Cause() error  { return e.err }
+
+// Permanent marks err as permanent, so that Retry stops immediately instead
+// of retrying the operation, regardless of what the operation or the
+// ErrorClassifier reports. It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns whether err, or any error it wraps, was marked with
+// Permanent.
+func IsPermanent(err error) bool {
+	return MatchesError[*permanentError](err)
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("%s (retry after %s)", e.err, e.delay)
+}
+func (e *retryAfterError) Unwrap() error { return e.err }
+func (e *retryAfterError) Cause() error  { return e.err }
+
+// RetryAfter marks err as retryable after the given delay, such as a delay
+// suggested by a server. Retry waits for this delay, capped at MaxDelay,
+// before the next attempt instead of using its backoff strategy, regardless
+// of what the operation or the ErrorClassifier reports. It returns nil if err
+// is nil.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// GetRetryAfter returns the delay requested by an error marked with
+// RetryAfter, and whether err, or any error it wraps, was marked.
+func GetRetryAfter(err error) (time.Duration, bool) {
+	var retryAfter *retryAfterError
+	if !errors.As(err, &retryAfter) {
+		return 0, false
+	}
+	return retryAfter.delay, true
+}
+
+// classifyRetry decides whether a failed attempt can be retried and, if the
+// error requests it, how long to wait before the next attempt. Error markers
+// take precedence over the classifier, which takes precedence over what the
+// operation reported.
+func classifyRetry(err error, canRetry bool, classify ErrorClassifier) (retry bool, delay time.Duration, hasDelay bool) {
+	if IsPermanent(err) {
+		return false, 0, false
+	}
+	if delay, ok := GetRetryAfter(err); ok {
+		return true, delay, true
+	}
+	if classify != nil {
+		return classify(err), 0, false
+	}
+	return canRetry, 0, false
+}
@@ -0,0 +1,122 @@
+package utility
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryErrorMarkers(t *testing.T) {
+	baseErr := errors.New("something went wrong")
+
+	t.Run("NilErrorsStayNil", func(t *testing.T) {
+		assert.NoError(t, Permanent(nil))
+		assert.NoError(t, RetryAfter(nil, time.Second))
+	})
+	t.Run("PermanentIsDetectedThroughWrapping", func(t *testing.T) {
+		err := pkgerrors.Wrap(Permanent(baseErr), "wrapped")
+		assert.True(t, IsPermanent(err))
+		assert.False(t, IsPermanent(baseErr))
+		assert.True(t, errors.Is(err, baseErr))
+		assert.Equal(t, baseErr, pkgerrors.Cause(err))
+		assert.Equal(t, "wrapped: something went wrong", err.Error())
+	})
+	t.Run("RetryAfterIsDetectedThroughWrapping", func(t *testing.T) {
+		err := pkgerrors.Wrap(RetryAfter(baseErr, time.Minute), "wrapped")
+		delay, ok := GetRetryAfter(err)
+		assert.True(t, ok)
+		assert.Equal(t, time.Minute, delay)
+		assert.True(t, errors.Is(err, baseErr))
+		assert.Equal(t, baseErr, pkgerrors.Cause(err))
+
+		_, ok = GetRetryAfter(baseErr)
+		assert.False(t, ok)
+	})
+}
+
+func TestRetryWithErrorMarkers(t *testing.T) {
+	baseErr := errors.New("something went wrong")
+	opts := RetryOptions{MaxAttempts: 5, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	t.Run("PermanentErrorStopsRetrying", func(t *testing.T) {
+		var calls int
+		err := Retry(t.Context(), func() (bool, error) {
+			calls++
+			return true, pkgerrors.Wrap(Permanent(baseErr), "wrapped")
+		}, opts)
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.True(t, errors.Is(err, baseErr))
+	})
+	t.Run("RetryAfterErrorIsRetriedAfterDelay", func(t *testing.T) {
+		slowOpts := opts
+		slowOpts.MaxDelay = time.Second
+
+		var calls int
+		start := time.Now()
+		err := Retry(t.Context(), func() (bool, error) {
+			calls++
+			if calls == 1 {
+				return false, RetryAfter(baseErr, 100*time.Millisecond)
+			}
+			return false, nil
+		}, slowOpts)
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+	t.Run("RetryAfterDelayIsCappedAtMaxDelay", func(t *testing.T) {
+		cappedOpts := opts
+		var delays []time.Duration
+		cappedOpts.OnRetry = func(_ int, _ error, delay time.Duration) {
+			delays = append(delays, delay)
+		}
+
+		var calls int
+		err := Retry(t.Context(), func() (bool, error) {
+			calls++
+			if calls == 1 {
+				return false, RetryAfter(baseErr, 24*time.Hour)
+			}
+			return false, nil
+		}, cappedOpts)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{opts.MaxDelay}, delays)
+	})
+	t.Run("ClassifierOverridesOperation", func(t *testing.T) {
+		retryableErr := errors.New("retryable")
+		classifyingOpts := opts
+		classifyingOpts.ClassifyError = func(err error) bool {
+			return errors.Is(err, retryableErr)
+		}
+
+		var calls int
+		err := Retry(t.Context(), func() (bool, error) {
+			calls++
+			if calls < 3 {
+				return false, pkgerrors.Wrap(retryableErr, "wrapped")
+			}
+			return true, baseErr
+		}, classifyingOpts)
+		require.Error(t, err)
+		assert.Equal(t, 3, calls)
+		assert.True(t, errors.Is(err, baseErr))
+	})
+	t.Run("MarkersTakePrecedenceOverClassifier", func(t *testing.T) {
+		classifyingOpts := opts
+		classifyingOpts.ClassifyError = func(error) bool { return true }
+
+		var calls int
+		_, err := RetryValue(context.Background(), func(context.Context, int) (int, bool, error) {
+			calls++
+			return 0, true, Permanent(baseErr)
+		}, classifyingOpts)
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,174 @@
+package utility
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	retryInstrumentationName = "github.com/evergreen-ci/utility/retry"
+	retryAttribute           = "evergreen.retry"
+	defaultRetryName         = "retry"
+
+	retryAttemptEvent = "retry.attempt"
+	retryOutcomeOK    = "success"
+)
+
+var (
+	retryNameAttribute        = fmt.Sprintf("%s.name", retryAttribute)
+	retryMaxAttemptsAttribute = fmt.Sprintf("%s.max_attempts", retryAttribute)
+	retryAttemptAttribute     = fmt.Sprintf("%s.attempt", retryAttribute)
+	retryErrorAttribute       = fmt.Sprintf("%s.error", retryAttribute)
+	retryDurationAttribute    = fmt.Sprintf("%s.duration_ms", retryAttribute)
+	retryDelayAttribute       = fmt.Sprintf("%s.delay_ms", retryAttribute)
+	retryAttemptsAttribute    = fmt.Sprintf("%s.attempts", retryAttribute)
+	retryOutcomeAttribute     = fmt.Sprintf("%s.outcome", retryAttribute)
+)
+
+// Names of the metrics recorded by instrumented retries.
+const (
+	RetryAttemptsMetric = "evergreen.retry.attempts"
+	RetryGiveUpsMetric  = "evergreen.retry.give_ups"
+)
+
+// retryInstruments caches the retry metric instruments for the global meter
+// provider, so that they are only created again if the global provider
+// changes.
+var retryInstruments struct {
+	mu             sync.Mutex
+	provider       metric.MeterProvider
+	attemptsHist   metric.Int64Histogram
+	giveUpsCounter metric.Int64Counter
+}
+
+// getRetryInstruments returns the retry metric instruments for the global
+// meter provider. Instruments that cannot be created are nil.
+func getRetryInstruments() (metric.Int64Histogram, metric.Int64Counter) {
+	retryInstruments.mu.Lock()
+	defer retryInstruments.mu.Unlock()
+
+	provider := otel.GetMeterProvider()
+	if provider == retryInstruments.provider {
+		return retryInstruments.attemptsHist, retryInstruments.giveUpsCounter
+	}
+
+	meter := provider.Meter(retryInstrumentationName)
+	attemptsHist, err := meter.Int64Histogram(RetryAttemptsMetric,
+		metric.WithDescription("Number of attempts made by a retried operation before it succeeded or gave up."),
+		metric.WithUnit("{attempt}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+		attemptsHist = nil
+	}
+	giveUpsCounter, err := meter.Int64Counter(RetryGiveUpsMetric,
+		metric.WithDescription("Number of retried operations that gave up without succeeding."),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+		giveUpsCounter = nil
+	}
+
+	retryInstruments.provider = provider
+	retryInstruments.attemptsHist = attemptsHist
+	retryInstruments.giveUpsCounter = giveUpsCounter
+	return attemptsHist, giveUpsCounter
+}
+
+// retryTelemetry instruments a single retried operation. A nil
+// *retryTelemetry records nothing, so that callers do not need to check
+// whether instrumentation is enabled.
+type retryTelemetry struct {
+	span       trace.Span
+	attributes []attribute.KeyValue
+}
+
+// startRetryTelemetry starts the span for a retried operation if the options
+// enable instrumentation, returning the context that attempts should use. The
+// tracer is taken from the global tracer provider each time so that changes to
+// the global provider take effect.
+func startRetryTelemetry(ctx context.Context, opts RetryOptions) (context.Context, *retryTelemetry) {
+	if !opts.Instrument {
+		return ctx, nil
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = defaultRetryName
+	}
+	// The context attributes may have many distinct values, such as request
+	// IDs, so they are only added to the span and not to the metrics.
+	spanAttributes := append([]attribute.KeyValue{
+		attribute.String(retryNameAttribute, name),
+		attribute.Int(retryMaxAttemptsAttribute, opts.MaxAttempts),
+	}, attributesFromContext(ctx)...)
+	ctx, span := otel.GetTracerProvider().Tracer(retryInstrumentationName).Start(ctx, name, trace.WithAttributes(spanAttributes...))
+
+	return ctx, &retryTelemetry{span: span, attributes: []attribute.KeyValue{attribute.String(retryNameAttribute, name)}}
+}
+
+// attempt records an event for a finished attempt. The delay is the time until
+// the next attempt, if there is one.
+func (t *retryTelemetry) attempt(attempt int, err error, duration, delay time.Duration) {
+	if t == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.Int(retryAttemptAttribute, attempt),
+		attribute.Int64(retryDurationAttribute, duration.Milliseconds()),
+	}
+	if err != nil {
+		attrs = append(attrs, attribute.String(retryErrorAttribute, err.Error()))
+	}
+	if delay > 0 {
+		attrs = append(attrs, attribute.Int64(retryDelayAttribute, delay.Milliseconds()))
+	}
+	t.span.AddEvent(retryAttemptEvent, trace.WithAttributes(attrs...))
+}
+
+// end records the outcome of the operation and ends its span. A nil error
+// means that the operation succeeded.
+func (t *retryTelemetry) end(ctx context.Context, attempts int, err *RetryError) {
+	if t == nil {
+		return
+	}
+	defer t.span.End()
+
+	outcome := retryOutcomeOK
+	if err != nil {
+		outcome = string(err.Reason)
+	}
+	t.span.SetAttributes(
+		attribute.Int(retryAttemptsAttribute, attempts),
+		attribute.String(retryOutcomeAttribute, outcome),
+	)
+
+	// The operation's context may already be done, but the measurements
+	// should still be recorded.
+	ctx = context.WithoutCancel(ctx)
+	attrs := metric.WithAttributes(append(t.attributes, attribute.String(retryOutcomeAttribute, outcome))...)
+	attemptsHist, giveUpsCounter := getRetryInstruments()
+	if attemptsHist != nil {
+		attemptsHist.Record(ctx, int64(attempts), attrs)
+	}
+
+	if err == nil {
+		t.span.SetStatus(codes.Ok, "")
+		return
+	}
+	t.span.RecordError(err)
+	t.span.SetStatus(codes.Error, err.Error())
+	if giveUpsCounter != nil {
+		giveUpsCounter.Add(ctx, 1, attrs)
+	}
+}
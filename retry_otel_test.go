@@ -0,0 +1,177 @@
+package utility
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRetryTelemetry(t *testing.T) {
+	// setupTelemetry replaces the global tracer and meter providers for the
+	// duration of the test.
+	setupTelemetry := func(t *testing.T) (*tracetest.SpanRecorder, sdkmetric.Reader) {
+		recorder := tracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(NewAttributeSpanProcessor()),
+			sdktrace.WithSpanProcessor(recorder),
+		)
+		reader := sdkmetric.NewManualReader()
+		meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+		originalTracerProvider := otel.GetTracerProvider()
+		originalMeterProvider := otel.GetMeterProvider()
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetMeterProvider(meterProvider)
+		t.Cleanup(func() {
+			otel.SetTracerProvider(originalTracerProvider)
+			otel.SetMeterProvider(originalMeterProvider)
+		})
+
+		return recorder, reader
+	}
+
+	// collectMetrics returns the metrics recorded by retries, keyed by name.
+	collectMetrics := func(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Metrics {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		metrics := map[string]metricdata.Metrics{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				metrics[m.Name] = m
+			}
+		}
+		return metrics
+	}
+
+	opts := RetryOptions{
+		MaxAttempts: 3,
+		MinDelay:    time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		Instrument:  true,
+		Name:        "fetch",
+	}
+	ctxAttr := attribute.String("evergreen.test.id", "abc")
+
+	t.Run("RecordsSuccessfulOperation", func(t *testing.T) {
+		recorder, reader := setupTelemetry(t)
+		ctx := ContextWithAttributes(t.Context(), []attribute.KeyValue{ctxAttr})
+
+		var attemptTraceID string
+		err := Retry(ctx, func() (bool, error) { return false, nil }, opts)
+		require.NoError(t, err)
+
+		_, err = RetryValue(ctx, func(ctx context.Context, attempt int) (int, bool, error) {
+			_, span := otel.GetTracerProvider().Tracer("test").Start(ctx, "child")
+			attemptTraceID = span.SpanContext().TraceID().String()
+			span.End()
+			if attempt < 2 {
+				return 0, true, errors.New("something went wrong")
+			}
+			return attempt, false, nil
+		}, opts)
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		var retrySpans []sdktrace.ReadOnlySpan
+		for _, span := range spans {
+			if span.Name() == "fetch" {
+				retrySpans = append(retrySpans, span)
+			}
+		}
+		require.Len(t, retrySpans, 2)
+		span := retrySpans[1]
+		assert.Equal(t, codes.Ok, span.Status().Code)
+		assert.Contains(t, span.Attributes(), ctxAttr, "span should have the context attributes")
+		assert.Contains(t, span.Attributes(), attribute.Int(retryAttemptsAttribute, 2))
+		assert.Equal(t, span.SpanContext().TraceID().String(), attemptTraceID, "attempts should be traced within the retry span")
+
+		events := span.Events()
+		require.Len(t, events, 2)
+		assert.Equal(t, retryAttemptEvent, events[0].Name)
+		assert.Contains(t, events[0].Attributes, attribute.Int(retryAttemptAttribute, 1))
+		assert.Contains(t, events[0].Attributes, attribute.String(retryErrorAttribute, "something went wrong"))
+		assert.Contains(t, events[0].Attributes, attribute.Int64(retryDelayAttribute, 1))
+		assert.Contains(t, events[1].Attributes, attribute.Int(retryAttemptAttribute, 2))
+		for _, attr := range events[1].Attributes {
+			assert.NotEqual(t, retryErrorAttribute, string(attr.Key), "successful attempt should not have an error")
+		}
+
+		metrics := collectMetrics(t, reader)
+		require.Contains(t, metrics, RetryAttemptsMetric)
+		hist, ok := metrics[RetryAttemptsMetric].Data.(metricdata.Histogram[int64])
+		require.True(t, ok)
+		require.Len(t, hist.DataPoints, 1)
+		assert.EqualValues(t, 2, hist.DataPoints[0].Count)
+		assert.EqualValues(t, 3, hist.DataPoints[0].Sum)
+		_, ok = hist.DataPoints[0].Attributes.Value(attribute.Key("evergreen.test.id"))
+		assert.False(t, ok, "metrics should not have the context attributes")
+		val, ok := hist.DataPoints[0].Attributes.Value(attribute.Key(retryNameAttribute))
+		require.True(t, ok)
+		assert.Equal(t, "fetch", val.AsString())
+
+		if giveUps, ok := metrics[RetryGiveUpsMetric]; ok {
+			sum, ok := giveUps.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			assert.Empty(t, sum.DataPoints, "successful operations should not count as give-ups")
+		}
+	})
+	t.Run("RecordsGiveUp", func(t *testing.T) {
+		recorder, reader := setupTelemetry(t)
+
+		err := Retry(t.Context(), func() (bool, error) {
+			return true, errors.New("something went wrong")
+		}, opts)
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Contains(t, span.Attributes(), attribute.String(retryOutcomeAttribute, string(RetryStopMaxAttempts)))
+
+		events := span.Events()
+		var attemptEvents int
+		for _, event := range events {
+			if event.Name == retryAttemptEvent {
+				attemptEvents++
+			}
+		}
+		assert.Equal(t, 3, attemptEvents)
+
+		metrics := collectMetrics(t, reader)
+		require.Contains(t, metrics, RetryGiveUpsMetric)
+		sum, ok := metrics[RetryGiveUpsMetric].Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, sum.DataPoints, 1)
+		assert.EqualValues(t, 1, sum.DataPoints[0].Value)
+		val, ok := sum.DataPoints[0].Attributes.Value(attribute.Key(retryOutcomeAttribute))
+		require.True(t, ok)
+		assert.Equal(t, string(RetryStopMaxAttempts), val.AsString())
+
+		hist, ok := metrics[RetryAttemptsMetric].Data.(metricdata.Histogram[int64])
+		require.True(t, ok)
+		require.Len(t, hist.DataPoints, 1)
+		assert.EqualValues(t, 3, hist.DataPoints[0].Sum)
+	})
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		recorder, reader := setupTelemetry(t)
+
+		uninstrumented := opts
+		uninstrumented.Instrument = false
+		require.NoError(t, Retry(t.Context(), func() (bool, error) { return false, nil }, uninstrumented))
+
+		assert.Empty(t, recorder.Ended())
+		assert.Empty(t, collectMetrics(t, reader))
+	})
+}
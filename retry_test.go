@@ -75,3 +75,350 @@ func TestRetry(t *testing.T) {
 		require.True(t, time.Since(now) < time.Millisecond)
 	})
 }
+
+func TestRetryValue(t *testing.T) {
+	const maxAttempts = 5
+	const minDelay = 10 * time.Millisecond
+
+	t.Run("ReturnsValueOnSuccess", func(t *testing.T) {
+		var attempts []int
+		val, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (string, bool, error) {
+			attempts = append(attempts, attempt)
+			if attempt < 3 {
+				return "", true, errors.New("something went wrong")
+			}
+			return "result", false, nil
+		}, RetryOptions{MaxAttempts: maxAttempts, MinDelay: minDelay})
+		require.NoError(t, err)
+		assert.Equal(t, "result", val)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+	})
+	t.Run("ReturnsLastValueAfterExhaustingAttempts", func(t *testing.T) {
+		val, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			return attempt, true, errors.New("something went wrong")
+		}, RetryOptions{MaxAttempts: maxAttempts, MinDelay: minDelay})
+		require.Error(t, err)
+		assert.Equal(t, maxAttempts, val)
+	})
+	t.Run("NonRetryableErrorFails", func(t *testing.T) {
+		var calls int
+		_, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			calls++
+			return 0, false, errors.New("something went wrong")
+		}, RetryOptions{MaxAttempts: maxAttempts, MinDelay: minDelay})
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("AttemptContextIsCanceledAfterAttempt", func(t *testing.T) {
+		var attemptCtxs []context.Context
+		_, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			require.NoError(t, ctx.Err())
+			attemptCtxs = append(attemptCtxs, ctx)
+			if attempt < 2 {
+				return 0, true, errors.New("something went wrong")
+			}
+			return 0, false, nil
+		}, RetryOptions{MaxAttempts: maxAttempts, MinDelay: minDelay})
+		require.NoError(t, err)
+		require.Len(t, attemptCtxs, 2)
+		for _, ctx := range attemptCtxs {
+			assert.Error(t, ctx.Err())
+		}
+	})
+	t.Run("AttemptContextInheritsFromParent", func(t *testing.T) {
+		type key struct{}
+		ctx := context.WithValue(t.Context(), key{}, "value")
+		_, err := RetryValue(ctx, func(ctx context.Context, attempt int) (int, bool, error) {
+			assert.Equal(t, "value", ctx.Value(key{}))
+			return 0, false, nil
+		}, RetryOptions{})
+		assert.NoError(t, err)
+	})
+	t.Run("StopsWhenContextIsCanceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		var calls int
+		_, err := RetryValue(ctx, func(ctx context.Context, attempt int) (int, bool, error) {
+			calls++
+			cancel()
+			return 0, true, errors.New("something went wrong")
+		}, RetryOptions{MaxAttempts: maxAttempts, MinDelay: minDelay})
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryTimeouts(t *testing.T) {
+	t.Run("AttemptTimeoutCancelsHungAttempt", func(t *testing.T) {
+		var calls int
+		start := time.Now()
+		_, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			calls++
+			if attempt == 1 {
+				<-ctx.Done()
+				return 0, true, ctx.Err()
+			}
+			return attempt, false, nil
+		}, RetryOptions{MaxAttempts: 3, MinDelay: time.Millisecond, AttemptTimeout: 50 * time.Millisecond})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+	t.Run("MaxElapsedTimeStopsRetrying", func(t *testing.T) {
+		var calls int
+		start := time.Now()
+		err := Retry(t.Context(), func() (bool, error) {
+			calls++
+			return true, errors.New("something went wrong")
+		}, RetryOptions{MaxAttempts: 1000, MinDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond, MaxElapsedTime: 100 * time.Millisecond})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum elapsed time")
+		assert.Less(t, calls, 1000)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+	t.Run("MaxElapsedTimeCancelsAttemptContext", func(t *testing.T) {
+		start := time.Now()
+		_, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			<-ctx.Done()
+			return 0, true, ctx.Err()
+		}, RetryOptions{MaxAttempts: 1000, MinDelay: time.Millisecond, MaxElapsedTime: 100 * time.Millisecond})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum elapsed time")
+		assert.Less(t, time.Since(start), time.Second)
+	})
+	t.Run("MaxElapsedTimeTakesPrecedenceOverNonRetryableError", func(t *testing.T) {
+		_, err := RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+			<-ctx.Done()
+			return 0, false, ctx.Err()
+		}, RetryOptions{MaxAttempts: 1000, MinDelay: time.Millisecond, MaxElapsedTime: 50 * time.Millisecond})
+		var retryErr *RetryError
+		require.ErrorAs(t, err, &retryErr)
+		assert.Equal(t, RetryStopMaxElapsedTime, retryErr.Reason)
+		assert.Len(t, retryErr.Attempts, 1)
+	})
+	t.Run("MaxAttemptsErrorNamesLimit", func(t *testing.T) {
+		err := Retry(t.Context(), func() (bool, error) {
+			return true, errors.New("something went wrong")
+		}, RetryOptions{MaxAttempts: 2, MinDelay: time.Millisecond, MaxElapsedTime: time.Minute})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 2 attempts")
+	})
+}
+
+func TestRetryLifecycle(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	t.Run("CallsHooksOnSuccess", func(t *testing.T) {
+		var retried []int
+		var succeeded int
+		hookOpts := opts
+		hookOpts.OnRetry = func(attempt int, err error, delay time.Duration) {
+			assert.Error(t, err)
+			assert.Equal(t, time.Millisecond, delay)
+			retried = append(retried, attempt)
+		}
+		hookOpts.OnSuccess = func(attempt int) { succeeded = attempt }
+		hookOpts.OnGiveUp = func(int, error) { assert.Fail(t, "should not give up") }
+
+		require.NoError(t, Retry(t.Context(), func() (bool, error) {
+			if len(retried) < 2 {
+				return true, errors.New("something went wrong")
+			}
+			return false, nil
+		}, hookOpts))
+		assert.Equal(t, []int{1, 2}, retried)
+		assert.Equal(t, 3, succeeded)
+	})
+	t.Run("CallsHooksOnGiveUp", func(t *testing.T) {
+		var retried int
+		var gaveUpAfter int
+		var gaveUpErr error
+		hookOpts := opts
+		hookOpts.OnRetry = func(int, error, time.Duration) { retried++ }
+		hookOpts.OnGiveUp = func(attempts int, err error) {
+			gaveUpAfter = attempts
+			gaveUpErr = err
+		}
+		hookOpts.OnSuccess = func(int) { assert.Fail(t, "should not succeed") }
+
+		err := Retry(t.Context(), func() (bool, error) {
+			return true, errors.New("something went wrong")
+		}, hookOpts)
+		require.Error(t, err)
+		assert.Equal(t, 2, retried)
+		assert.Equal(t, 3, gaveUpAfter)
+		assert.Equal(t, err, gaveUpErr)
+	})
+	t.Run("RetryErrorRecordsAttempts", func(t *testing.T) {
+		err := Retry(t.Context(), func() (bool, error) {
+			time.Sleep(time.Millisecond)
+			return true, errors.New("something went wrong")
+		}, opts)
+		require.Error(t, err)
+
+		var retryErr *RetryError
+		require.True(t, errors.As(errors.Wrap(err, "wrapped"), &retryErr))
+		assert.Equal(t, RetryStopMaxAttempts, retryErr.Reason)
+		require.Len(t, retryErr.Attempts, 3)
+		for i, attempt := range retryErr.Attempts {
+			assert.Equal(t, i+1, attempt.Number)
+			assert.EqualError(t, attempt.Err, "something went wrong")
+			assert.GreaterOrEqual(t, attempt.Duration, time.Millisecond)
+		}
+		last, ok := retryErr.LastAttempt()
+		require.True(t, ok)
+		assert.Equal(t, 3, last.Number)
+		assert.Contains(t, err.Error(), "after 3 attempts")
+	})
+	t.Run("RetryErrorUnwrapsToLastError", func(t *testing.T) {
+		baseErr := errors.New("something went wrong")
+		err := Retry(t.Context(), func() (bool, error) {
+			return false, baseErr
+		}, opts)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, baseErr))
+		assert.Equal(t, baseErr, errors.Cause(err))
+		assert.Equal(t, baseErr.Error(), err.Error())
+
+		var retryErr *RetryError
+		require.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, RetryStopNonRetryable, retryErr.Reason)
+		assert.Len(t, retryErr.Attempts, 1)
+	})
+	t.Run("RetryErrorForContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		err := Retry(ctx, func() (bool, error) {
+			cancel()
+			return true, errors.New("something went wrong")
+		}, opts)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled))
+
+		var retryErr *RetryError
+		require.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, RetryStopContextDone, retryErr.Reason)
+		require.Len(t, retryErr.Attempts, 1)
+		assert.EqualError(t, retryErr.Attempts[0].Err, "something went wrong")
+	})
+}
+
+func TestRetryWithFakeClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// advanceOnRetry advances the clock by d each time the retry signals that
+	// it is about to wait, once it is waiting on the clock, until it returns.
+	advanceOnRetry := func(t *testing.T, clock *FakeClock, d time.Duration, retrying <-chan struct{}, done <-chan struct{}) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-retrying:
+				require.NoError(t, clock.BlockUntil(t.Context(), 1))
+				clock.Advance(d)
+			}
+		}
+	}
+
+	t.Run("WaitsForExactDelays", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var (
+			delays       []time.Duration
+			attemptTimes []time.Time
+			err          error
+		)
+		retrying := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			err = Retry(t.Context(), func() (bool, error) {
+				attemptTimes = append(attemptTimes, clock.Now())
+				return true, errors.New("something went wrong")
+			}, RetryOptions{
+				MaxAttempts: 3,
+				MinDelay:    time.Minute,
+				MaxDelay:    time.Hour,
+				Backoff:     LinearBackoff{},
+				Clock:       clock,
+				OnRetry: func(_ int, _ error, delay time.Duration) {
+					delays = append(delays, delay)
+					go func() { retrying <- struct{}{} }()
+				},
+			})
+		}()
+		for i := 0; i < 2; i++ {
+			<-retrying
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+			clock.Advance(delays[i])
+		}
+		<-done
+
+		require.Error(t, err)
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute}, delays)
+		assert.Equal(t, []time.Time{start, start.Add(time.Minute), start.Add(3 * time.Minute)}, attemptTimes)
+	})
+	t.Run("MaxElapsedTimeUsesClock", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var (
+			calls int
+			err   error
+		)
+		retrying := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			err = Retry(t.Context(), func() (bool, error) {
+				calls++
+				return true, errors.New("something went wrong")
+			}, RetryOptions{
+				MaxAttempts:    100,
+				MinDelay:       time.Minute,
+				Backoff:        ConstantBackoff{},
+				MaxElapsedTime: 5*time.Minute + time.Second,
+				Clock:          clock,
+				OnRetry: func(int, error, time.Duration) {
+					go func() { retrying <- struct{}{} }()
+				},
+			})
+		}()
+		advanceOnRetry(t, clock, time.Minute, retrying, done)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum elapsed time")
+		assert.Equal(t, 6, calls)
+	})
+	t.Run("AttemptTimeoutUsesClock", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var (
+			result int
+			err    error
+		)
+		retrying := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			result, err = RetryValue(t.Context(), func(ctx context.Context, attempt int) (int, bool, error) {
+				if attempt == 1 {
+					<-ctx.Done()
+					return 0, true, context.Cause(ctx)
+				}
+				return attempt, false, nil
+			}, RetryOptions{
+				MaxAttempts:    2,
+				MinDelay:       time.Second,
+				Backoff:        ConstantBackoff{},
+				AttemptTimeout: time.Hour,
+				Clock:          clock,
+				OnRetry: func(_ int, err error, _ time.Duration) {
+					assert.ErrorIs(t, err, context.DeadlineExceeded)
+					go func() { retrying <- struct{}{} }()
+				},
+			})
+		}()
+		require.NoError(t, clock.BlockUntil(t.Context(), 1))
+		clock.Advance(time.Hour)
+		advanceOnRetry(t, clock, time.Second, retrying, done)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result)
+	})
+}
@@ -0,0 +1,306 @@
+package utility
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sseMediaType         = "text/event-stream"
+	sseLastEventIDHeader = "Last-Event-ID"
+	sseDefaultEventType  = "message"
+	sseMaxLineSize       = 1024 * 1024 // 1 MB
+)
+
+// SSEEvent is a single event received from a Server-Sent Events stream.
+type SSEEvent struct {
+	// ID is the last event ID set by the stream, which may have been set by an
+	// earlier event.
+	ID string
+	// Event is the event type. By default, it is "message".
+	Event string
+	// Data is the event payload. Multiple data lines are joined by newlines.
+	Data string
+	// Retry is the reconnection delay requested by the server with this
+	// event, if any.
+	Retry time.Duration
+}
+
+// SSEClientOptions configure an SSEClient.
+type SSEClientOptions struct {
+	// URL is the event stream to connect to.
+	URL string
+	// Header contains additional headers to send with each connection
+	// request.
+	Header http.Header
+	// LastEventID is sent with the first connection request to resume a
+	// stream from a known event.
+	LastEventID string
+	// RetryOptions configures the backoff between reconnection attempts.
+	// MaxAttempts is the number of consecutive failed connection attempts
+	// before giving up; it resets whenever a connection succeeds. If the
+	// server sends a retry hint, the hint is used instead of the backoff.
+	RetryOptions RetryOptions
+	// Client is the HTTP client used to connect to the stream. Its timeout
+	// applies to the entire stream, so it should usually be 0. By default, a
+	// client from the pool is used with no timeout.
+	Client *http.Client
+}
+
+// Validate checks that the required options are set and sets defaults for
+// unspecified options.
+func (o *SSEClientOptions) Validate() error {
+	if o.URL == "" {
+		return errors.New("must specify a URL")
+	}
+	o.RetryOptions.Validate()
+	return nil
+}
+
+// SSEClient consumes a Server-Sent Events stream, reconnecting automatically
+// when the connection is lost.
+type SSEClient struct {
+	opts        SSEClientOptions
+	lastEventID string
+	retryHint   time.Duration
+}
+
+// NewSSEClient returns a client for the event stream at the configured URL.
+func NewSSEClient(opts SSEClientOptions) (*SSEClient, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	return &SSEClient{
+		opts:        opts,
+		lastEventID: opts.LastEventID,
+	}, nil
+}
+
+// LastEventID returns the most recent event ID received from the stream,
+// which is sent when reconnecting.
+func (c *SSEClient) LastEventID() string { return c.lastEventID }
+
+// Events connects to the stream and returns an iterator over its events. The
+// connection is closed when the loop exits or the context is done. If the
+// stream cannot be (re)established, the iterator yields a final non-nil error
+// and stops. A 204 (no content) response stops the iteration without an
+// error. Events should not be called concurrently on the same client.
+func (c *SSEClient) Events(ctx context.Context) iter.Seq2[SSEEvent, error] {
+	return func(yield func(SSEEvent, error) bool) {
+		client := c.opts.Client
+		if client == nil {
+			client = GetHTTPClient()
+			client.Timeout = 0
+			defer PutHTTPClient(client)
+		}
+
+		backoff := getBackoff(c.opts.RetryOptions)
+		var failures int
+		for {
+			connected, stop, err := c.stream(ctx, client, yield)
+			if stop {
+				return
+			}
+			if ctx.Err() != nil {
+				yield(SSEEvent{}, errors.Wrap(ctx.Err(), "streaming events"))
+				return
+			}
+			if isPermanentSSEError(err) {
+				yield(SSEEvent{}, err)
+				return
+			}
+
+			if connected {
+				failures = 0
+				backoff.Reset()
+			} else {
+				failures++
+				if failures >= c.opts.RetryOptions.MaxAttempts {
+					yield(SSEEvent{}, errors.Wrapf(err, "after %d attempts, failed to connect to event stream", failures))
+					return
+				}
+			}
+
+			delay := c.retryHint
+			if delay <= 0 {
+				delay = backoff.Duration()
+			}
+			select {
+			case <-ctx.Done():
+				yield(SSEEvent{}, errors.Wrap(ctx.Err(), "waiting to reconnect to event stream"))
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// stream makes a single connection to the event stream and yields its events
+// until the stream ends. It returns whether the connection was established
+// and whether the iteration should stop without reconnecting.
+func (c *SSEClient) stream(ctx context.Context, client *http.Client, yield func(SSEEvent, error) bool) (connected, stop bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.opts.URL, nil)
+	if err != nil {
+		return false, false, permanentSSEError{errors.Wrap(err, "creating request")}
+	}
+	for key, values := range c.opts.Header {
+		for _, val := range values {
+			req.Header.Add(key, val)
+		}
+	}
+	req.Header.Set("Accept", sseMediaType)
+	req.Header.Set("Cache-Control", "no-cache")
+	if c.lastEventID != "" {
+		req.Header.Set(sseLastEventIDHeader, c.lastEventID)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, false, errors.Wrap(err, "connecting to event stream")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return true, true, nil
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return false, false, errors.Errorf("event stream returned status %d", resp.StatusCode)
+	default:
+		return false, false, permanentSSEError{errors.Errorf("event stream returned status %d", resp.StatusCode)}
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != sseMediaType {
+		return false, false, permanentSSEError{errors.Errorf("event stream returned content type '%s'", resp.Header.Get("Content-Type"))}
+	}
+
+	err = parseSSE(resp.Body, c.lastEventID, func(retry time.Duration) {
+		c.retryHint = retry
+	}, func(id string) {
+		c.lastEventID = id
+	}, func(event SSEEvent) bool {
+		return yield(event, nil)
+	})
+	if err == errSSEStopped {
+		return true, true, nil
+	}
+
+	return true, false, err
+}
+
+// permanentSSEError indicates that reconnecting to the stream will not
+// succeed.
+type permanentSSEError struct{ error }
+
+func (e permanentSSEError) Unwrap() error { return e.error }
+
+func isPermanentSSEError(err error) bool {
+	return MatchesError[permanentSSEError](err)
+}
+
+var errSSEStopped = errors.New("event handler stopped the stream")
+
+// parseSSE parses the event stream in r according to the Server-Sent Events
+// specification, calling handle for each complete event and setRetry for each
+// valid retry field as soon as it is read. The last event ID starts as
+// lastEventID, since it carries over from previous connections, and
+// setLastEventID is called with it at the end of every block, including
+// blocks without data that do not dispatch an event. It returns
+// errSSEStopped if handle returns false, and nil once r is exhausted.
+// Incomplete events at the end of the stream are discarded.
+func parseSSE(r io.Reader, lastEventID string, setRetry func(time.Duration), setLastEventID func(string), handle func(SSEEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), sseMaxLineSize)
+	scanner.Split(scanSSELines)
+
+	var (
+		event   SSEEvent
+		data    strings.Builder
+		hasData bool
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			setLastEventID(lastEventID)
+			if hasData {
+				event.ID = lastEventID
+				event.Data = strings.TrimSuffix(data.String(), "\n")
+				if event.Event == "" {
+					event.Event = sseDefaultEventType
+				}
+				if !handle(event) {
+					return errSSEStopped
+				}
+			}
+			event = SSEEvent{}
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+				setRetry(event.Retry)
+			}
+		}
+	}
+
+	return errors.Wrap(scanner.Err(), "reading event stream")
+}
+
+// scanSSELines is a bufio.SplitFunc that splits lines terminated by "\r\n",
+// "\n" or "\r", as required by the Server-Sent Events specification.
+func scanSSELines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// Wait for more data to check whether the "\r" is part of "\r\n".
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,327 @@
+package utility
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSSE(t *testing.T) {
+	parse := func(t *testing.T, stream string) ([]SSEEvent, time.Duration) {
+		var events []SSEEvent
+		var retry time.Duration
+		require.NoError(t, parseSSE(strings.NewReader(stream), "", func(d time.Duration) { retry = d }, func(string) {}, func(event SSEEvent) bool {
+			events = append(events, event)
+			return true
+		}))
+		return events, retry
+	}
+
+	t.Run("ParsesAllFields", func(t *testing.T) {
+		events, retry := parse(t, "event: update\ndata: hello\nid: 1\nretry: 250\n\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, SSEEvent{ID: "1", Event: "update", Data: "hello", Retry: 250 * time.Millisecond}, events[0])
+		assert.Equal(t, 250*time.Millisecond, retry)
+	})
+	t.Run("DefaultsEventType", func(t *testing.T) {
+		events, _ := parse(t, "data: hello\n\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, "message", events[0].Event)
+	})
+	t.Run("JoinsMultipleDataLines", func(t *testing.T) {
+		events, _ := parse(t, "data: first\ndata: second\n\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, "first\nsecond", events[0].Data)
+	})
+	t.Run("IgnoresCommentsAndUnknownFields", func(t *testing.T) {
+		events, _ := parse(t, ": keepalive\nunknown: field\ndata: hello\n\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, "hello", events[0].Data)
+	})
+	t.Run("HandlesAllLineEndings", func(t *testing.T) {
+		events, _ := parse(t, "data: one\r\n\r\ndata: two\r\rdata: three\n\n")
+		require.Len(t, events, 3)
+		assert.Equal(t, "one", events[0].Data)
+		assert.Equal(t, "two", events[1].Data)
+		assert.Equal(t, "three", events[2].Data)
+	})
+	t.Run("KeepsLastEventIDForLaterEvents", func(t *testing.T) {
+		events, _ := parse(t, "id: 5\ndata: first\n\ndata: second\n\n")
+		require.Len(t, events, 2)
+		assert.Equal(t, "5", events[0].ID)
+		assert.Equal(t, "5", events[1].ID)
+	})
+	t.Run("SkipsEventsWithoutData", func(t *testing.T) {
+		events, _ := parse(t, "event: empty\n\ndata: hello\n\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, "message", events[0].Event)
+	})
+	t.Run("ReportsIDWithoutData", func(t *testing.T) {
+		var ids []string
+		require.NoError(t, parseSSE(strings.NewReader("id: 1\ndata: first\n\nid: 2\n\n"), "0", func(time.Duration) {}, func(id string) {
+			ids = append(ids, id)
+		}, func(SSEEvent) bool { return true }))
+		assert.Equal(t, []string{"1", "2"}, ids)
+	})
+	t.Run("IgnoresInvalidRetry", func(t *testing.T) {
+		events, retry := parse(t, "retry: soon\ndata: hello\n\n")
+		require.Len(t, events, 1)
+		assert.Zero(t, events[0].Retry)
+		assert.Zero(t, retry)
+	})
+	t.Run("DiscardsIncompleteEvent", func(t *testing.T) {
+		events, _ := parse(t, "data: complete\n\ndata: incomplete")
+		require.Len(t, events, 1)
+		assert.Equal(t, "complete", events[0].Data)
+	})
+	t.Run("StopsWhenHandlerReturnsFalse", func(t *testing.T) {
+		var count int
+		err := parseSSE(strings.NewReader("data: one\n\ndata: two\n\n"), "", func(time.Duration) {}, func(string) {}, func(SSEEvent) bool {
+			count++
+			return false
+		})
+		assert.Equal(t, errSSEStopped, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestSSEClient(t *testing.T) {
+	retryOpts := RetryOptions{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	writeEvents := func(w http.ResponseWriter, events ...string) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range events {
+			fmt.Fprint(w, event)
+		}
+	}
+	collect := func(t *testing.T, client *SSEClient, n int) ([]SSEEvent, error) {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		var events []SSEEvent
+		for event, err := range client.Events(ctx) {
+			if err != nil {
+				return events, err
+			}
+			events = append(events, event)
+			if len(events) == n {
+				break
+			}
+		}
+		return events, nil
+	}
+
+	t.Run("RequiresURL", func(t *testing.T) {
+		_, err := NewSSEClient(SSEClientOptions{})
+		assert.Error(t, err)
+	})
+	t.Run("ReceivesEvents", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			assert.Equal(t, "value", r.Header.Get("X-Custom"))
+			writeEvents(w, "id: 1\ndata: first\n\n", "id: 2\ndata: second\n\n")
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, Header: http.Header{"X-Custom": []string{"value"}}, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		events, err := collect(t, client, 2)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, "first", events[0].Data)
+		assert.Equal(t, "second", events[1].Data)
+		assert.Equal(t, "2", client.LastEventID())
+	})
+	t.Run("ReconnectsWithLastEventID", func(t *testing.T) {
+		var mu sync.Mutex
+		var lastEventIDs []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+			connection := len(lastEventIDs)
+			mu.Unlock()
+
+			writeEvents(w, fmt.Sprintf("id: %d\nretry: 1\ndata: event %d\n\n", connection, connection))
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, LastEventID: "0", RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		events, err := collect(t, client, 3)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, "event 3", events[2].Data)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"0", "1", "2"}, lastEventIDs)
+	})
+	t.Run("ReconnectsWithIDFromBlockWithoutData", func(t *testing.T) {
+		var mu sync.Mutex
+		var lastEventIDs []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+			connection := len(lastEventIDs)
+			mu.Unlock()
+
+			writeEvents(w, fmt.Sprintf("id: %d\nretry: 1\ndata: event %d\n\nid: %d-skipped\n\n", connection, connection, connection))
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		_, err = collect(t, client, 2)
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"", "1-skipped"}, lastEventIDs)
+	})
+	t.Run("HonorsServerRetryHint", func(t *testing.T) {
+		var mu sync.Mutex
+		var connectedAt []time.Time
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			connectedAt = append(connectedAt, time.Now())
+			mu.Unlock()
+
+			writeEvents(w, "retry: 200\ndata: hello\n\n")
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		_, err = collect(t, client, 2)
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, connectedAt, 2)
+		assert.GreaterOrEqual(t, connectedAt[1].Sub(connectedAt[0]), 200*time.Millisecond)
+	})
+	t.Run("RetriesFailedConnections", func(t *testing.T) {
+		var mu sync.Mutex
+		var attempts int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			attempts++
+			attempt := attempts
+			mu.Unlock()
+
+			if attempt < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeEvents(w, "data: hello\n\n")
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		events, err := collect(t, client, 1)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		_, err = collect(t, client, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 3 attempts")
+	})
+	t.Run("DoesNotRetryClientErrors", func(t *testing.T) {
+		var mu sync.Mutex
+		var attempts int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		_, err = collect(t, client, 1)
+		require.Error(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, attempts)
+	})
+	t.Run("DoesNotRetryWrongContentType", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		_, err = collect(t, client, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "content type")
+	})
+	t.Run("StopsOnNoContent", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		events, err := collect(t, client, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+	t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeEvents(w, "data: hello\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		client, err := NewSSEClient(SSEClientOptions{URL: srv.URL, RetryOptions: retryOpts})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var events []SSEEvent
+		var lastErr error
+		for event, err := range client.Events(ctx) {
+			if err != nil {
+				lastErr = err
+				break
+			}
+			events = append(events, event)
+			cancel()
+		}
+		assert.Len(t, events, 1)
+		require.Error(t, lastErr)
+		assert.ErrorIs(t, lastErr, context.Canceled)
+	})
+}
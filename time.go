@@ -1,7 +1,7 @@
 package utility
 
 import (
-	"math/rand"
+	"math/rand/v2"
 	"time"
 )
 
@@ -78,20 +78,39 @@ func ToPythonTime(t time.Time) float64 {
 // JitterInterval returns a duration that some value between the
 // interval and 2x the interval.
 func JitterInterval(interval time.Duration) time.Duration {
-	return time.Duration(rand.Float64()*float64(interval)) + interval
+	return JitterIntervalWithRand(nil, interval)
+}
+
+// JitterIntervalWithRand is the same as JitterInterval, but draws the jitter
+// from r so that it can be reproduced with a seeded generator. If r is nil,
+// the global generator is used.
+func JitterIntervalWithRand(r *rand.Rand, interval time.Duration) time.Duration {
+	return time.Duration(randFloat64(r)*float64(interval)) + interval
 }
 
 // RoundPartOfDay produces a time value with the hour value
 // rounded down to the most recent interval.
-func RoundPartOfDay(n int) time.Time { return findPartHour(time.Now(), n) }
+func RoundPartOfDay(n int) time.Time { return RoundPartOfDayWithClock(RealClock{}, n) }
+
+// RoundPartOfDayWithClock is the same as RoundPartOfDay, but uses the given
+// clock for the current time.
+func RoundPartOfDayWithClock(clock Clock, n int) time.Time { return findPartHour(clock.Now(), n) }
 
 // RoundPartOfHour produces a time value with the minute value
 // rounded down to the most recent interval.
-func RoundPartOfHour(n int) time.Time { return findPartMin(time.Now(), n) }
+func RoundPartOfHour(n int) time.Time { return RoundPartOfHourWithClock(RealClock{}, n) }
+
+// RoundPartOfHourWithClock is the same as RoundPartOfHour, but uses the given
+// clock for the current time.
+func RoundPartOfHourWithClock(clock Clock, n int) time.Time { return findPartMin(clock.Now(), n) }
 
 // RoundPartOfMinute produces a time value with the second value
 // rounded down to the most recent interval.
-func RoundPartOfMinute(n int) time.Time { return findPartSec(time.Now(), n) }
+func RoundPartOfMinute(n int) time.Time { return RoundPartOfMinuteWithClock(RealClock{}, n) }
+
+// RoundPartOfMinuteWithClock is the same as RoundPartOfMinute, but uses the
+// given clock for the current time.
+func RoundPartOfMinuteWithClock(clock Clock, n int) time.Time { return findPartSec(clock.Now(), n) }
 
 // this implements the logic of RoundPartOfDay, but takes time as an
 // argument for testability.
@@ -2,6 +2,7 @@ package utility
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"testing"
 	"time"
 
@@ -18,6 +19,21 @@ func TestTimeJitter(t *testing.T) {
 	}
 }
 
+func TestTimeJitterWithRand(t *testing.T) {
+	jitters := func(r *rand.Rand) []time.Duration {
+		var jitters []time.Duration
+		for i := 0; i < 10; i++ {
+			jitter := JitterIntervalWithRand(r, 15*time.Second)
+			assert.GreaterOrEqual(t, jitter, 15*time.Second)
+			assert.LessOrEqual(t, jitter, 30*time.Second)
+			jitters = append(jitters, jitter)
+		}
+		return jitters
+	}
+
+	assert.Equal(t, jitters(rand.New(rand.NewPCG(1, 2))), jitters(rand.New(rand.NewPCG(1, 2))))
+}
+
 func TestTimeRoundPartHour(t *testing.T) {
 	assert := assert.New(t)
 
@@ -177,3 +193,11 @@ func getTimeWithSec(sec int) time.Time {
 
 	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), sec, 0, time.UTC)
 }
+
+func TestTimeRoundPartWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, time.March, 4, 17, 43, 29, 0, time.UTC))
+
+	assert.Equal(t, time.Date(2020, time.March, 4, 16, 0, 0, 0, time.UTC), RoundPartOfDayWithClock(clock, 4))
+	assert.Equal(t, time.Date(2020, time.March, 4, 17, 40, 0, 0, time.UTC), RoundPartOfHourWithClock(clock, 10))
+	assert.Equal(t, time.Date(2020, time.March, 4, 17, 43, 15, 0, time.UTC), RoundPartOfMinuteWithClock(clock, 15))
+}
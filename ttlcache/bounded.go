@@ -0,0 +1,350 @@
+package ttlcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/pkg/errors"
+)
+
+// EvictionPolicy determines which entry a bounded cache evicts when it is
+// full.
+type EvictionPolicy string
+
+const (
+	// EvictLRU evicts the least recently used entry.
+	EvictLRU EvictionPolicy = "lru"
+	// EvictLFU evicts the least frequently used entry, and the least recently
+	// used entry among those that are used equally often.
+	EvictLFU EvictionPolicy = "lfu"
+)
+
+// BoundedOptions configure a size-bounded ttl cache. At least one of
+// MaxEntries and MaxCost must be set.
+type BoundedOptions[T any] struct {
+	// Policy determines which entries are evicted when the cache is full. By
+	// default, it is EvictLRU.
+	Policy EvictionPolicy
+	// MaxEntries, if set, is the maximum number of entries in the cache.
+	MaxEntries int
+	// MaxCost, if set, is the maximum total cost of the entries in the cache.
+	// A value that costs more than MaxCost on its own is not cached.
+	MaxCost int64
+	// Cost returns the cost of a value, such as its size in bytes. By default,
+	// every value costs 1.
+	Cost func(T) int64
+	// Clock is used to determine how long entries have left before they
+	// expire. By default, it is the system clock.
+	Clock utility.Clock
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *BoundedOptions[T]) Validate() error {
+	if o.Policy == "" {
+		o.Policy = EvictLRU
+	}
+	if o.Cost == nil {
+		o.Cost = func(T) int64 { return 1 }
+	}
+	if o.Clock == nil {
+		o.Clock = utility.RealClock{}
+	}
+
+	if o.Policy != EvictLRU && o.Policy != EvictLFU {
+		return errors.Errorf("unknown eviction policy '%s'", o.Policy)
+	}
+	if o.MaxEntries < 0 || o.MaxCost < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if o.MaxEntries == 0 && o.MaxCost == 0 {
+		return errors.New("must specify a maximum number of entries or a maximum cost")
+	}
+	return nil
+}
+
+// NewBounded creates a new thread-safe ttl cache that evicts entries according
+// to its policy once it exceeds its limits. Get, Put and Delete take constant
+// time.
+func NewBounded[T any](opts BoundedOptions[T]) (*BoundedCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	c := &BoundedCache[T]{
+		opts:    opts,
+		entries: make(map[string]*boundedEntry[T]),
+	}
+	switch opts.Policy {
+	case EvictLRU:
+		c.policy = newLRUPolicy[T]()
+	case EvictLFU:
+		c.policy = newLFUPolicy[T]()
+	}
+	return c, nil
+}
+
+type BoundedCache[T any] struct {
+	opts BoundedOptions[T]
+
+	mu      sync.Mutex
+	entries map[string]*boundedEntry[T]
+	cost    int64
+	policy  evictionPolicy[T]
+
+	listeners evictListeners[T]
+	// evicted holds the evictions made while the lock is held, which are
+	// passed to the listeners once it is released.
+	evicted []eviction[T]
+}
+
+// boundedEntry is a cache entry along with the bookkeeping for its eviction
+// policy.
+type boundedEntry[T any] struct {
+	id string
+	ttlValue[T]
+	cost int64
+
+	// elem is the entry's element in its policy's list.
+	elem *list.Element
+	// bucket is the element of the entry's frequency bucket for LFU.
+	bucket *list.Element
+}
+
+func (c *BoundedCache[T]) Get(_ context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var zero T
+	e, ok := c.entries[id]
+	if !ok {
+		return zero, false
+	}
+	now := c.opts.Clock.Now()
+	if e.expired(now) {
+		c.remove(e, EvictionExpired)
+		return zero, false
+	}
+	if e.expiresAt.Sub(now) < minimumLifetime {
+		return zero, false
+	}
+
+	c.policy.access(e)
+	return e.value, true
+}
+
+func (c *BoundedCache[T]) Put(_ context.Context, id string, value T, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	cost := max(c.opts.Cost(value), 0)
+	e, ok := c.entries[id]
+	if c.opts.MaxCost > 0 && cost > c.opts.MaxCost {
+		if ok {
+			c.remove(e, EvictionCapacity)
+		}
+		return
+	}
+
+	if ok {
+		c.evict(id, e.value, EvictionReplaced)
+		c.cost += cost - e.cost
+		e.value = value
+		e.expiresAt = expiresAt
+		e.cost = cost
+		c.policy.access(e)
+	} else {
+		e = &boundedEntry[T]{
+			id:       id,
+			ttlValue: ttlValue[T]{value: value, expiresAt: expiresAt},
+			cost:     cost,
+		}
+		c.entries[id] = e
+		c.cost += cost
+		c.policy.add(e)
+	}
+
+	for c.overLimit() {
+		victim := c.policy.victim(e)
+		if victim == nil {
+			break
+		}
+		c.remove(victim, EvictionCapacity)
+	}
+}
+
+func (c *BoundedCache[T]) Delete(_ context.Context, id string) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if e, ok := c.entries[id]; ok {
+		c.remove(e, EvictionDeleted)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not been purged.
+func (c *BoundedCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Purge removes every expired entry from the cache and returns the number of
+// entries removed. Unlike the other operations, it takes time proportional to
+// the number of entries.
+func (c *BoundedCache[T]) Purge(_ context.Context) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.opts.Clock.Now()
+	var purged int
+	for _, e := range c.entries {
+		if e.expired(now) {
+			c.remove(e, EvictionExpired)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (c *BoundedCache[T]) overLimit() bool {
+	return (c.opts.MaxEntries > 0 && len(c.entries) > c.opts.MaxEntries) ||
+		(c.opts.MaxCost > 0 && c.cost > c.opts.MaxCost)
+}
+
+// OnEvict registers a function to call when an entry leaves the cache because
+// it was replaced, deleted, evicted to stay within the cache's limits, or
+// found to have expired.
+func (c *BoundedCache[T]) OnEvict(fn EvictFunc[T]) {
+	c.listeners.add(fn)
+}
+
+// remove removes an entry from the cache for the given reason. It must be
+// called with the lock held.
+func (c *BoundedCache[T]) remove(e *boundedEntry[T], reason EvictionReason) {
+	c.policy.remove(e)
+	delete(c.entries, e.id)
+	c.cost -= e.cost
+	c.evict(e.id, e.value, reason)
+}
+
+// evict records that an entry left the cache. It must be called with the lock
+// held.
+func (c *BoundedCache[T]) evict(id string, value T, reason EvictionReason) {
+	if c.listeners.enabled() {
+		c.evicted = append(c.evicted, eviction[T]{id: id, value: value, reason: reason})
+	}
+}
+
+// unlock releases the lock and then notifies the listeners of the evictions
+// made while it was held.
+func (c *BoundedCache[T]) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.mu.Unlock()
+
+	c.listeners.notify(evicted)
+}
+
+// evictionPolicy orders the entries of a bounded cache for eviction. Every
+// method must take constant time.
+type evictionPolicy[T any] interface {
+	// add starts tracking a new entry.
+	add(e *boundedEntry[T])
+	// access records a use of an entry.
+	access(e *boundedEntry[T])
+	// remove stops tracking an entry.
+	remove(e *boundedEntry[T])
+	// victim returns the entry to evict next other than skip, or nil if there
+	// is none.
+	victim(skip *boundedEntry[T]) *boundedEntry[T]
+}
+
+// lruPolicy keeps entries in order of use, with the most recently used at the
+// front.
+type lruPolicy[T any] struct {
+	order *list.List
+}
+
+func newLRUPolicy[T any]() *lruPolicy[T] {
+	return &lruPolicy[T]{order: list.New()}
+}
+
+func (p *lruPolicy[T]) add(e *boundedEntry[T]) { e.elem = p.order.PushFront(e) }
+
+func (p *lruPolicy[T]) access(e *boundedEntry[T]) { p.order.MoveToFront(e.elem) }
+
+func (p *lruPolicy[T]) remove(e *boundedEntry[T]) { p.order.Remove(e.elem) }
+
+func (p *lruPolicy[T]) victim(skip *boundedEntry[T]) *boundedEntry[T] {
+	for elem := p.order.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*boundedEntry[T]); e != skip {
+			return e
+		}
+	}
+	return nil
+}
+
+// lfuPolicy groups entries into buckets by their number of uses, with the
+// buckets in increasing order of use. Each bucket keeps its entries in order
+// of use, with the most recently used at the front. Since entries only ever
+// move to the next bucket, every operation takes constant time.
+type lfuPolicy[T any] struct {
+	buckets *list.List
+}
+
+// lfuBucket holds the entries that have been used the same number of times.
+type lfuBucket struct {
+	uses    int
+	entries *list.List
+}
+
+func newLFUPolicy[T any]() *lfuPolicy[T] {
+	return &lfuPolicy[T]{buckets: list.New()}
+}
+
+func (p *lfuPolicy[T]) add(e *boundedEntry[T]) {
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).uses != 1 {
+		front = p.buckets.PushFront(&lfuBucket{uses: 1, entries: list.New()})
+	}
+	e.bucket = front
+	e.elem = front.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (p *lfuPolicy[T]) access(e *boundedEntry[T]) {
+	current := e.bucket
+	uses := current.Value.(*lfuBucket).uses
+	next := current.Next()
+	if next == nil || next.Value.(*lfuBucket).uses != uses+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{uses: uses + 1, entries: list.New()}, current)
+	}
+
+	p.remove(e)
+	e.bucket = next
+	e.elem = next.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (p *lfuPolicy[T]) remove(e *boundedEntry[T]) {
+	bucket := e.bucket.Value.(*lfuBucket)
+	bucket.entries.Remove(e.elem)
+	if bucket.entries.Len() == 0 {
+		p.buckets.Remove(e.bucket)
+	}
+}
+
+func (p *lfuPolicy[T]) victim(skip *boundedEntry[T]) *boundedEntry[T] {
+	for bucket := p.buckets.Front(); bucket != nil; bucket = bucket.Next() {
+		for elem := bucket.Value.(*lfuBucket).entries.Back(); elem != nil; elem = elem.Prev() {
+			if e := elem.Value.(*boundedEntry[T]); e != skip {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package ttlcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundedOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		opts := BoundedOptions[int]{MaxEntries: 1}
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, EvictLRU, opts.Policy)
+		require.NotNil(t, opts.Cost)
+		assert.EqualValues(t, 1, opts.Cost(22))
+		assert.NotNil(t, opts.Clock)
+	})
+	t.Run("RejectsInvalidOptions", func(t *testing.T) {
+		for name, opts := range map[string]BoundedOptions[int]{
+			"NoLimits":        {},
+			"NegativeEntries": {MaxEntries: -1, MaxCost: 1},
+			"NegativeCost":    {MaxEntries: 1, MaxCost: -1},
+			"UnknownPolicy":   {MaxEntries: 1, Policy: "fifo"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.Error(t, opts.Validate())
+			})
+		}
+	})
+}
+
+func TestBoundedCache(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+		t.Run(string(policy), func(t *testing.T) {
+			testCache(t, func() Cache[*int] {
+				cache, err := NewBounded(BoundedOptions[*int]{Policy: policy, MaxEntries: 10})
+				require.NoError(t, err)
+				return cache
+			})
+		})
+	}
+
+	t.Run("OnEvict", func(t *testing.T) {
+		for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+			t.Run(string(policy), func(t *testing.T) {
+				testOnEvict(t, func(clock utility.Clock) evictingCache {
+					cache, err := NewBounded(BoundedOptions[*int]{Policy: policy, MaxEntries: 10, Clock: clock})
+					require.NoError(t, err)
+					return cache
+				})
+			})
+		}
+	})
+
+	newCache := func(t *testing.T, opts BoundedOptions[int]) *BoundedCache[int] {
+		cache, err := NewBounded(opts)
+		require.NoError(t, err)
+		return cache
+	}
+	has := func(t *testing.T, cache *BoundedCache[int], id string) bool {
+		_, ok := cache.Get(t.Context(), id, 0)
+		return ok
+	}
+	// ids returns the ids in the cache without recording any uses.
+	ids := func(cache *BoundedCache[int]) []string {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		var ids []string
+		for id := range cache.entries {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("LRUEvictsLeastRecentlyUsed", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{Policy: EvictLRU, MaxEntries: 2})
+		cache.Put(t.Context(), "a", 1, expiresAt)
+		cache.Put(t.Context(), "b", 2, expiresAt)
+		require.True(t, has(t, cache, "a"))
+
+		cache.Put(t.Context(), "c", 3, expiresAt)
+		assert.ElementsMatch(t, []string{"a", "c"}, ids(cache))
+	})
+	t.Run("LFUEvictsLeastFrequentlyUsed", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{Policy: EvictLFU, MaxEntries: 3})
+		cache.Put(t.Context(), "a", 1, expiresAt)
+		cache.Put(t.Context(), "b", 2, expiresAt)
+		cache.Put(t.Context(), "c", 3, expiresAt)
+		for i := 0; i < 3; i++ {
+			require.True(t, has(t, cache, "a"))
+		}
+		require.True(t, has(t, cache, "b"))
+		require.True(t, has(t, cache, "c"))
+		require.True(t, has(t, cache, "c"))
+
+		cache.Put(t.Context(), "d", 4, expiresAt)
+		assert.ElementsMatch(t, []string{"a", "c", "d"}, ids(cache))
+
+		cache.Put(t.Context(), "e", 5, expiresAt)
+		assert.ElementsMatch(t, []string{"a", "c", "e"}, ids(cache), "the new entry should not be evicted to make room for itself")
+	})
+	t.Run("LFUBreaksTiesByRecency", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{Policy: EvictLFU, MaxEntries: 2})
+		cache.Put(t.Context(), "a", 1, expiresAt)
+		cache.Put(t.Context(), "b", 2, expiresAt)
+		require.True(t, has(t, cache, "b"))
+		require.True(t, has(t, cache, "a"))
+
+		cache.Put(t.Context(), "c", 3, expiresAt)
+		assert.ElementsMatch(t, []string{"a", "c"}, ids(cache))
+	})
+	t.Run("LFUKeepsUsesWhenReplaced", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{Policy: EvictLFU, MaxEntries: 2})
+		cache.Put(t.Context(), "a", 1, expiresAt)
+		cache.Put(t.Context(), "b", 2, expiresAt)
+		require.True(t, has(t, cache, "a"))
+		cache.Put(t.Context(), "a", 10, expiresAt)
+
+		cache.Put(t.Context(), "c", 3, expiresAt)
+		assert.ElementsMatch(t, []string{"a", "c"}, ids(cache))
+		val, ok := cache.Get(t.Context(), "a", 0)
+		require.True(t, ok)
+		assert.Equal(t, 10, val)
+	})
+	t.Run("DeleteFreesCapacity", func(t *testing.T) {
+		for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+			t.Run(string(policy), func(t *testing.T) {
+				cache := newCache(t, BoundedOptions[int]{Policy: policy, MaxEntries: 2})
+				cache.Put(t.Context(), "a", 1, expiresAt)
+				cache.Put(t.Context(), "b", 2, expiresAt)
+				cache.Delete(t.Context(), "a")
+				cache.Delete(t.Context(), "missing")
+
+				cache.Put(t.Context(), "c", 3, expiresAt)
+				assert.ElementsMatch(t, []string{"b", "c"}, ids(cache))
+			})
+		}
+	})
+	t.Run("BoundedByCost", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{
+			MaxCost: 10,
+			Cost:    func(v int) int64 { return int64(v) },
+		})
+		cache.Put(t.Context(), "a", 4, expiresAt)
+		cache.Put(t.Context(), "b", 4, expiresAt)
+		assert.EqualValues(t, 8, cache.cost)
+
+		cache.Put(t.Context(), "c", 6, expiresAt)
+		assert.ElementsMatch(t, []string{"b", "c"}, ids(cache))
+		assert.EqualValues(t, 10, cache.cost)
+
+		t.Run("ReplacingUpdatesCost", func(t *testing.T) {
+			cache.Put(t.Context(), "c", 2, expiresAt)
+			assert.ElementsMatch(t, []string{"b", "c"}, ids(cache))
+			assert.EqualValues(t, 6, cache.cost)
+
+			cache.Put(t.Context(), "b", 9, expiresAt)
+			assert.ElementsMatch(t, []string{"b"}, ids(cache), "growing an entry should evict others")
+			assert.EqualValues(t, 9, cache.cost)
+		})
+		t.Run("DoesNotCacheOversizedValues", func(t *testing.T) {
+			cache.Put(t.Context(), "huge", 11, expiresAt)
+			assert.False(t, has(t, cache, "huge"))
+			assert.ElementsMatch(t, []string{"b"}, ids(cache))
+
+			cache.Put(t.Context(), "b", 11, expiresAt)
+			assert.Empty(t, ids(cache), "replacing an entry with an oversized value should remove it")
+			assert.Zero(t, cache.cost)
+		})
+	})
+	t.Run("BoundedByEntriesAndCost", func(t *testing.T) {
+		cache := newCache(t, BoundedOptions[int]{
+			MaxEntries: 2,
+			MaxCost:    100,
+			Cost:       func(v int) int64 { return int64(v) },
+		})
+		cache.Put(t.Context(), "a", 1, expiresAt)
+		cache.Put(t.Context(), "b", 1, expiresAt)
+		cache.Put(t.Context(), "c", 1, expiresAt)
+		assert.ElementsMatch(t, []string{"b", "c"}, ids(cache))
+	})
+	t.Run("RespectsExpiration", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, BoundedOptions[int]{MaxEntries: 10, Clock: clock})
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		_, ok := cache.Get(t.Context(), "key", 2*time.Hour)
+		assert.False(t, ok, "entry should not be returned without enough remaining lifetime")
+		assert.ElementsMatch(t, []string{"key"}, ids(cache))
+
+		val, ok := cache.Get(t.Context(), "key", time.Hour)
+		require.True(t, ok)
+		assert.Equal(t, 22, val)
+
+		clock.Advance(time.Hour + time.Second)
+		_, ok = cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok)
+		assert.Empty(t, ids(cache), "expired entry should be removed when accessed")
+	})
+	t.Run("Purge", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, BoundedOptions[int]{Policy: EvictLFU, MaxEntries: 10, Clock: clock})
+		cache.Put(t.Context(), "short", 1, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "long", 2, clock.Now().Add(time.Hour))
+
+		assert.Zero(t, cache.Purge(t.Context()))
+
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, 1, cache.Purge(t.Context()))
+		assert.ElementsMatch(t, []string{"long"}, ids(cache))
+	})
+	t.Run("OnEvictCapacityAndExpiration", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, BoundedOptions[int]{
+			MaxEntries: 2,
+			MaxCost:    10,
+			Cost:       func(v int) int64 { return int64(v) },
+			Clock:      clock,
+		})
+		var evictions []recordedEviction
+		cache.OnEvict(func(id string, value int, reason EvictionReason) {
+			evictions = append(evictions, recordedEviction{id: id, value: value, reason: reason})
+		})
+
+		cache.Put(t.Context(), "a", 1, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "b", 2, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "c", 3, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "c", 11, clock.Now().Add(time.Hour))
+		assert.Equal(t, []recordedEviction{
+			{id: "a", value: 1, reason: EvictionCapacity},
+			{id: "c", value: 3, reason: EvictionCapacity},
+		}, evictions)
+
+		evictions = nil
+		clock.Advance(2 * time.Hour)
+		_, ok := cache.Get(t.Context(), "b", 0)
+		require.False(t, ok)
+		assert.Equal(t, []recordedEviction{{id: "b", value: 2, reason: EvictionExpired}}, evictions)
+	})
+	t.Run("ConcurrentAccess", func(t *testing.T) {
+		for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+			t.Run(string(policy), func(t *testing.T) {
+				cache := newCache(t, BoundedOptions[int]{Policy: policy, MaxEntries: 16})
+
+				var wg sync.WaitGroup
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < 200; j++ {
+							id := fmt.Sprint((i * j) % 32)
+							cache.Put(context.Background(), id, j, expiresAt)
+							cache.Get(context.Background(), id, 0)
+							if j%7 == 0 {
+								cache.Delete(context.Background(), id)
+							}
+						}
+					}()
+				}
+				wg.Wait()
+
+				assert.LessOrEqual(t, len(ids(cache)), 16)
+			})
+		}
+	})
+}
@@ -2,6 +2,7 @@ package ttlcache
 
 import (
 	"context"
+	"iter"
 	"time"
 )
 
@@ -16,8 +17,46 @@ type Cache[T any] interface {
 	Delete(ctx context.Context, id string)
 }
 
+// BulkCache is a Cache that can operate on many entries at once and whose
+// contents can be inspected.
+type BulkCache[T any] interface {
+	Cache[T]
+	// GetMany gets the values with the given ids that have at least the
+	// minimum lifetime remaining. Ids that are not found are omitted from the
+	// result.
+	GetMany(ctx context.Context, ids []string, minimumLifetime time.Duration) map[string]T
+	// PutMany adds the entries to the cache, keyed by id.
+	PutMany(ctx context.Context, entries map[string]Entry[T])
+	// DeleteMany removes the values with the given ids from the cache. Ids
+	// that are not found are ignored.
+	DeleteMany(ctx context.Context, ids []string)
+	// Len returns the number of entries in the cache, including expired
+	// entries that have not been removed.
+	Len() int
+	// Clear removes every entry from the cache.
+	Clear(ctx context.Context)
+	// Keys returns the ids of the entries that have not expired, in sorted
+	// order.
+	Keys(ctx context.Context) []string
+	// All returns an iterator over the entries that have not expired, keyed
+	// by id. The entries are read when iteration starts, so the cache may be
+	// used while iterating.
+	All(ctx context.Context) iter.Seq2[string, LiveEntry[T]]
+}
+
+// LiveEntry is a value in a cache along with its remaining lifetime.
+type LiveEntry[T any] struct {
+	Value T
+	TTL   time.Duration
+}
+
 // ttlValue is a generic type that holds a value and an expiration time.
 type ttlValue[T any] struct {
 	value     T
 	expiresAt time.Time
 }
+
+// expired returns whether the value has expired at the given time.
+func (v ttlValue[T]) expired(now time.Time) bool {
+	return v.expiresAt.Before(now)
+}
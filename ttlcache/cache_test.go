@@ -1,9 +1,12 @@
 package ttlcache
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/evergreen-ci/utility"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -64,3 +67,199 @@ func testCache(t *testing.T, cacheFunc func() Cache[*int]) {
 		})
 	})
 }
+
+// evictingCache is a cache that reports evictions and can purge expired
+// entries.
+type evictingCache interface {
+	Cache[*int]
+	OnEvict(EvictFunc[*int])
+	Purge(context.Context) int
+}
+
+// evictionRecorder records the evictions reported by a cache.
+type evictionRecorder struct {
+	mu        sync.Mutex
+	evictions []recordedEviction
+}
+
+type recordedEviction struct {
+	id     string
+	value  int
+	reason EvictionReason
+}
+
+func (r *evictionRecorder) record(id string, value *int, reason EvictionReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := recordedEviction{id: id, reason: reason}
+	if value != nil {
+		e.value = *value
+	}
+	r.evictions = append(r.evictions, e)
+}
+
+func (r *evictionRecorder) get() []recordedEviction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]recordedEviction(nil), r.evictions...)
+}
+
+// testOnEvict checks the evictions that every implementation reports. The
+// cache must use the given clock.
+func testOnEvict(t *testing.T, cacheFunc func(clock utility.Clock) evictingCache) {
+	newCache := func() (evictingCache, *utility.FakeClock, *evictionRecorder) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := cacheFunc(clock)
+		recorder := &evictionRecorder{}
+		cache.OnEvict(recorder.record)
+		return cache, clock, recorder
+	}
+	first, second := 1, 2
+
+	t.Run("Replaced", func(t *testing.T) {
+		cache, clock, recorder := newCache()
+		cache.Put(t.Context(), "key", &first, clock.Now().Add(time.Hour))
+		assert.Empty(t, recorder.get())
+
+		cache.Put(t.Context(), "key", &second, clock.Now().Add(time.Hour))
+		assert.Equal(t, []recordedEviction{{id: "key", value: 1, reason: EvictionReplaced}}, recorder.get())
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		cache, clock, recorder := newCache()
+		cache.Put(t.Context(), "key", &first, clock.Now().Add(time.Hour))
+		cache.Delete(t.Context(), "key")
+		cache.Delete(t.Context(), "missing")
+		assert.Equal(t, []recordedEviction{{id: "key", value: 1, reason: EvictionDeleted}}, recorder.get())
+	})
+	t.Run("Expired", func(t *testing.T) {
+		cache, clock, recorder := newCache()
+		cache.Put(t.Context(), "short", &first, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "long", &second, clock.Now().Add(time.Hour))
+
+		clock.Advance(2 * time.Minute)
+		require.Equal(t, 1, cache.Purge(t.Context()))
+		assert.Equal(t, []recordedEviction{{id: "short", value: 1, reason: EvictionExpired}}, recorder.get())
+	})
+	t.Run("NotifiesEveryListener", func(t *testing.T) {
+		cache, clock, recorder := newCache()
+		other := &evictionRecorder{}
+		cache.OnEvict(other.record)
+		cache.Put(t.Context(), "key", &first, clock.Now().Add(time.Hour))
+		cache.Delete(t.Context(), "key")
+
+		assert.Len(t, recorder.get(), 1)
+		assert.Len(t, other.get(), 1)
+	})
+	t.Run("CallbackCanUseCache", func(t *testing.T) {
+		cache, clock, _ := newCache()
+		cache.OnEvict(func(id string, _ *int, _ EvictionReason) {
+			cache.Put(context.Background(), id+"-evicted", &second, clock.Now().Add(time.Hour))
+		})
+		cache.Put(t.Context(), "key", &first, clock.Now().Add(time.Hour))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cache.Delete(context.Background(), "key")
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			require.FailNow(t, "callback should not be called with the lock held")
+		}
+		_, ok := cache.Get(t.Context(), "key-evicted", 0)
+		assert.True(t, ok)
+	})
+}
+
+// testBulkCache checks the bulk operations and introspection of a BulkCache.
+// The cache must use the given clock.
+func testBulkCache(t *testing.T, cacheFunc func(clock utility.Clock) BulkCache[*int]) {
+	newCache := func() (BulkCache[*int], *utility.FakeClock) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		return cacheFunc(clock), clock
+	}
+	first, second, third := 1, 2, 3
+
+	t.Run("PutManyAndGetMany", func(t *testing.T) {
+		cache, clock := newCache()
+		cache.PutMany(t.Context(), map[string]Entry[*int]{
+			"a": {Value: &first, ExpiresAt: clock.Now().Add(time.Hour)},
+			"b": {Value: &second, ExpiresAt: clock.Now().Add(time.Minute)},
+		})
+
+		values := cache.GetMany(t.Context(), []string{"a", "b", "missing"}, 0)
+		assert.Equal(t, map[string]*int{"a": &first, "b": &second}, values)
+
+		values = cache.GetMany(t.Context(), []string{"a", "b"}, 30*time.Minute)
+		assert.Equal(t, map[string]*int{"a": &first}, values, "values without the minimum lifetime should be omitted")
+		assert.Empty(t, cache.GetMany(t.Context(), nil, 0))
+	})
+	t.Run("DeleteMany", func(t *testing.T) {
+		cache, clock := newCache()
+		cache.Put(t.Context(), "a", &first, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "b", &second, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "c", &third, clock.Now().Add(time.Hour))
+
+		cache.DeleteMany(t.Context(), []string{"a", "c", "missing"})
+		assert.Equal(t, 1, cache.Len())
+		assert.Equal(t, []string{"b"}, cache.Keys(t.Context()))
+	})
+	t.Run("Clear", func(t *testing.T) {
+		cache, clock := newCache()
+		cache.Put(t.Context(), "a", &first, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "b", &second, clock.Now().Add(time.Minute))
+
+		cache.Clear(t.Context())
+		assert.Zero(t, cache.Len())
+		_, ok := cache.Get(t.Context(), "a", 0)
+		assert.False(t, ok)
+
+		cache.Put(t.Context(), "a", &first, clock.Now().Add(time.Hour))
+		assert.Equal(t, 1, cache.Len(), "the cache should be usable after it is cleared")
+	})
+	t.Run("Introspection", func(t *testing.T) {
+		cache, clock := newCache()
+		cache.Put(t.Context(), "c", &third, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "a", &first, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "b", &second, clock.Now().Add(2*time.Hour))
+		clock.Advance(2 * time.Minute)
+
+		assert.Equal(t, 3, cache.Len(), "expired entries should count until they are removed")
+		assert.Equal(t, []string{"a", "b"}, cache.Keys(t.Context()))
+
+		entries := map[string]LiveEntry[*int]{}
+		for id, entry := range cache.All(t.Context()) {
+			entries[id] = entry
+		}
+		assert.Equal(t, map[string]LiveEntry[*int]{
+			"a": {Value: &first, TTL: 58 * time.Minute},
+			"b": {Value: &second, TTL: 118 * time.Minute},
+		}, entries)
+
+		t.Run("StopsEarly", func(t *testing.T) {
+			var n int
+			for range cache.All(t.Context()) {
+				n++
+				break
+			}
+			assert.Equal(t, 1, n)
+		})
+		t.Run("CanUseCacheWhileIterating", func(t *testing.T) {
+			for id := range cache.All(t.Context()) {
+				cache.Delete(t.Context(), id)
+			}
+			assert.Empty(t, cache.Keys(t.Context()))
+		})
+	})
+	t.Run("EmptyCache", func(t *testing.T) {
+		cache, _ := newCache()
+		assert.Zero(t, cache.Len())
+		assert.Empty(t, cache.Keys(t.Context()))
+		for range cache.All(t.Context()) {
+			assert.Fail(t, "an empty cache should have no entries")
+		}
+	})
+}
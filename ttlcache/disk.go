@@ -0,0 +1,328 @@
+package ttlcache
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/pkg/errors"
+)
+
+const (
+	diskEntryExtension = ".entry"
+	diskTempPrefix     = ".tmp-"
+	// diskTempMaxAge is how old a temporary file must be before Purge
+	// removes it. Temporary files are only left behind if the process exits
+	// while writing an entry.
+	diskTempMaxAge = time.Hour
+)
+
+// Codec encodes and decodes the entries of a disk-backed cache.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes entries as JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// DiskOptions configure a disk-backed ttl cache.
+type DiskOptions struct {
+	// Dir is the directory in which entries are stored. It is created if it
+	// does not exist. It must not be shared with other caches.
+	Dir string
+	// Codec encodes and decodes entries. By default, it is JSONCodec.
+	Codec Codec
+	// Clock is used to determine how long entries have left before they
+	// expire. By default, it is the system clock.
+	Clock utility.Clock
+	// OnError is called with errors reading or writing entries, since the
+	// cache methods cannot return them. By default, errors are ignored.
+	OnError func(error)
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *DiskOptions) Validate() error {
+	if o.Codec == nil {
+		o.Codec = JSONCodec{}
+	}
+	if o.Clock == nil {
+		o.Clock = utility.RealClock{}
+	}
+	if o.OnError == nil {
+		o.OnError = func(error) {}
+	}
+
+	if o.Dir == "" {
+		return errors.New("must specify a directory")
+	}
+	return nil
+}
+
+// NewDisk creates a new ttl cache that stores each entry in its own file in
+// the directory, so that entries persist across process restarts. Entries are
+// written atomically, so readers never see a partially written entry. It is
+// safe for concurrent use by multiple goroutines, but not by multiple
+// processes writing to the same directory.
+func NewDisk[T any](opts DiskOptions) (*DiskCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "creating cache directory '%s'", opts.Dir)
+	}
+	return &DiskCache[T]{opts: opts}, nil
+}
+
+type DiskCache[T any] struct {
+	opts DiskOptions
+
+	// mu serializes changes to the directory so that a stale entry is never
+	// removed in place of a newer one.
+	mu sync.Mutex
+
+	listeners evictListeners[T]
+	// evicted holds the evictions made while the lock is held, which are
+	// passed to the listeners once it is released.
+	evicted []eviction[T]
+}
+
+// diskRecord is the contents of an entry's file. The id is stored since the
+// file is named after its hash.
+type diskRecord[T any] struct {
+	ID        string    `json:"id" yaml:"id"`
+	Value     T         `json:"value" yaml:"value"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// expired returns whether the entry has expired at the given time.
+func (r diskRecord[T]) expired(now time.Time) bool {
+	return r.ExpiresAt.Before(now)
+}
+
+func (c *DiskCache[T]) Get(_ context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	var zero T
+	record, ok := c.read(c.path(id))
+	if !ok || record.ExpiresAt.Sub(c.opts.Clock.Now()) < minimumLifetime {
+		return zero, false
+	}
+	return record.Value, true
+}
+
+func (c *DiskCache[T]) Put(_ context.Context, id string, value T, expiresAt time.Time) {
+	data, err := c.opts.Codec.Marshal(diskRecord[T]{ID: id, Value: value, ExpiresAt: expiresAt})
+	if err != nil {
+		c.opts.OnError(errors.Wrapf(err, "encoding entry for id '%s'", id))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	path := c.path(id)
+	var old diskRecord[T]
+	var replaced bool
+	if c.listeners.enabled() {
+		old, replaced = c.read(path)
+	}
+	if err := c.write(path, data); err != nil {
+		c.opts.OnError(errors.Wrapf(err, "writing entry for id '%s'", id))
+		return
+	}
+	if replaced {
+		c.evict(id, old.Value, EvictionReplaced)
+	}
+}
+
+func (c *DiskCache[T]) Delete(_ context.Context, id string) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	path := c.path(id)
+	var old diskRecord[T]
+	var deleted bool
+	if c.listeners.enabled() {
+		old, deleted = c.read(path)
+	}
+	if err := os.Remove(path); err != nil {
+		if !os.IsNotExist(err) {
+			c.opts.OnError(errors.Wrapf(err, "removing entry for id '%s'", id))
+		}
+		return
+	}
+	if deleted {
+		c.evict(id, old.Value, EvictionDeleted)
+	}
+}
+
+// Purge removes every expired entry from the cache, along with any entries
+// that cannot be decoded and any temporary files left behind by interrupted
+// writes, and returns the number of entries removed. Entries that cannot be
+// read, such as because of a permissions error, are reported to OnError and
+// kept.
+func (c *DiskCache[T]) Purge(_ context.Context) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	files, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		c.opts.OnError(errors.Wrapf(err, "reading cache directory '%s'", c.opts.Dir))
+		return 0
+	}
+
+	now := c.opts.Clock.Now()
+	var purged int
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.opts.Dir, file.Name())
+		if strings.HasPrefix(file.Name(), diskTempPrefix) {
+			c.purgeTemp(path, file)
+			continue
+		}
+		if !strings.HasSuffix(file.Name(), diskEntryExtension) {
+			continue
+		}
+
+		record, corrupt, err := c.load(path)
+		if err != nil && !corrupt {
+			if !errors.Is(err, fs.ErrNotExist) {
+				c.opts.OnError(err)
+			}
+			continue
+		}
+		if err == nil && !record.expired(now) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			c.opts.OnError(errors.Wrapf(err, "removing entry '%s'", path))
+			continue
+		}
+		if !corrupt {
+			c.evict(record.ID, record.Value, EvictionExpired)
+		}
+		purged++
+	}
+	return purged
+}
+
+// purgeTemp removes the temporary file if it is old enough that it cannot
+// belong to a write in progress. Its age is measured in real time, since it
+// is compared to the file's modification time.
+func (c *DiskCache[T]) purgeTemp(path string, file fs.DirEntry) {
+	info, err := file.Info()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.opts.OnError(errors.Wrapf(err, "reading temporary file '%s'", path))
+		}
+		return
+	}
+	if time.Since(info.ModTime()) < diskTempMaxAge {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		c.opts.OnError(errors.Wrapf(err, "removing temporary file '%s'", path))
+	}
+}
+
+// path returns the path of the file for the entry with id. Ids are hashed so
+// that any id can be used as a file name.
+func (c *DiskCache[T]) path(id string) string {
+	h := utility.NewSHA256Hash()
+	h.Add(id)
+	return filepath.Join(c.opts.Dir, h.Sum()+diskEntryExtension)
+}
+
+// OnEvict registers a function to call when an entry leaves the cache because
+// it was replaced, deleted, or purged after it expired. Entries that cannot be
+// decoded are removed without calling it.
+func (c *DiskCache[T]) OnEvict(fn EvictFunc[T]) {
+	c.listeners.add(fn)
+}
+
+// evict records that an entry left the cache. It must be called with the lock
+// held.
+func (c *DiskCache[T]) evict(id string, value T, reason EvictionReason) {
+	if c.listeners.enabled() {
+		c.evicted = append(c.evicted, eviction[T]{id: id, value: value, reason: reason})
+	}
+}
+
+// unlock releases the lock and then notifies the listeners of the evictions
+// made while it was held.
+func (c *DiskCache[T]) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.mu.Unlock()
+
+	c.listeners.notify(evicted)
+}
+
+// read reads the entry in the file, returning false if it does not exist or
+// cannot be read or decoded.
+func (c *DiskCache[T]) read(path string) (diskRecord[T], bool) {
+	record, _, err := c.load(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.opts.OnError(err)
+		}
+		return diskRecord[T]{}, false
+	}
+	return record, true
+}
+
+// load reads and decodes the entry in the file. It also returns whether the
+// file was read but could not be decoded, in which case the entry is corrupt
+// rather than temporarily unreadable.
+func (c *DiskCache[T]) load(path string) (record diskRecord[T], corrupt bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return diskRecord[T]{}, false, errors.Wrapf(err, "reading entry '%s'", path)
+	}
+	if err := c.opts.Codec.Unmarshal(data, &record); err != nil {
+		return diskRecord[T]{}, true, errors.Wrapf(err, "decoding entry '%s'", path)
+	}
+	return record, false, nil
+}
+
+// write atomically replaces the contents of the file by writing the data to a
+// temporary file and renaming it.
+func (c *DiskCache[T]) write(path string, data []byte) error {
+	tmp, err := os.CreateTemp(c.opts.Dir, diskTempPrefix+"*")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file")
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		// This fails harmlessly if the file was renamed.
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "writing temporary file '%s'", tmpPath)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "syncing temporary file '%s'", tmpPath)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "closing temporary file '%s'", tmpPath)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrapf(err, "renaming temporary file '%s' to '%s'", tmpPath, path)
+	}
+	return nil
+}
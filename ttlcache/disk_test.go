@@ -0,0 +1,236 @@
+package ttlcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestDiskOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		opts := DiskOptions{Dir: t.TempDir()}
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, JSONCodec{}, opts.Codec)
+		assert.NotNil(t, opts.Clock)
+		assert.NotNil(t, opts.OnError)
+	})
+	t.Run("RequiresDir", func(t *testing.T) {
+		var opts DiskOptions
+		assert.Error(t, opts.Validate())
+	})
+}
+
+func TestDiskCache(t *testing.T) {
+	testCache(t, func() Cache[*int] {
+		cache, err := NewDisk[*int](DiskOptions{Dir: t.TempDir()})
+		require.NoError(t, err)
+		return cache
+	})
+
+	t.Run("OnEvict", func(t *testing.T) {
+		testOnEvict(t, func(clock utility.Clock) evictingCache {
+			cache, err := NewDisk[*int](DiskOptions{Dir: t.TempDir(), Clock: clock})
+			require.NoError(t, err)
+			return cache
+		})
+	})
+
+	// entryFiles returns the names of the files in the directory.
+	entryFiles := func(t *testing.T, dir string) []string {
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		return names
+	}
+
+	t.Run("CreatesDir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nested", "cache")
+		_, err := NewDisk[int](DiskOptions{Dir: dir})
+		require.NoError(t, err)
+		assert.DirExists(t, dir)
+	})
+	t.Run("PersistsAcrossInstances", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := NewDisk[string](DiskOptions{Dir: dir})
+		require.NoError(t, err)
+		cache.Put(t.Context(), "https://example.com/api?q=1", "response", time.Now().Add(time.Hour))
+
+		reopened, err := NewDisk[string](DiskOptions{Dir: dir})
+		require.NoError(t, err)
+		val, ok := reopened.Get(t.Context(), "https://example.com/api?q=1", time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, "response", val)
+
+		reopened.Delete(t.Context(), "https://example.com/api?q=1")
+		_, ok = cache.Get(t.Context(), "https://example.com/api?q=1", 0)
+		assert.False(t, ok)
+		assert.Empty(t, entryFiles(t, dir))
+	})
+	t.Run("UsesCodec", func(t *testing.T) {
+		type token struct {
+			Value  string
+			Scopes []string
+		}
+		dir := t.TempDir()
+		cache, err := NewDisk[token](DiskOptions{Dir: dir, Codec: gobCodec{}})
+		require.NoError(t, err)
+		expected := token{Value: "secret", Scopes: []string{"read", "write"}}
+		cache.Put(t.Context(), "key", expected, time.Now().Add(time.Hour))
+
+		val, ok := cache.Get(t.Context(), "key", time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, expected, val)
+
+		files := entryFiles(t, dir)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(filepath.Join(dir, files[0]))
+		require.NoError(t, err)
+		var record diskRecord[token]
+		require.NoError(t, gobCodec{}.Unmarshal(data, &record))
+		assert.Equal(t, "key", record.ID)
+		assert.Equal(t, expected, record.Value)
+	})
+	t.Run("UsesClock", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache, err := NewDisk[int](DiskOptions{Dir: t.TempDir(), Clock: clock})
+		require.NoError(t, err)
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		_, ok := cache.Get(t.Context(), "key", time.Hour)
+		require.True(t, ok)
+		clock.Advance(time.Minute)
+		_, ok = cache.Get(t.Context(), "key", time.Hour)
+		assert.False(t, ok)
+		_, ok = cache.Get(t.Context(), "key", 59*time.Minute)
+		assert.True(t, ok)
+	})
+	t.Run("ReportsCorruptEntries", func(t *testing.T) {
+		dir := t.TempDir()
+		var errs []error
+		cache, err := NewDisk[int](DiskOptions{Dir: dir, OnError: func(err error) { errs = append(errs, err) }})
+		require.NoError(t, err)
+		cache.Put(t.Context(), "key", 22, time.Now().Add(time.Hour))
+		require.Empty(t, errs)
+
+		_, ok := cache.Get(t.Context(), "missing", 0)
+		assert.False(t, ok)
+		assert.Empty(t, errs, "missing entries should not be reported as errors")
+
+		files := entryFiles(t, dir)
+		require.Len(t, files, 1)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, files[0]), []byte("{not json"), 0600))
+		_, ok = cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok)
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "decoding entry")
+
+		assert.Equal(t, 1, cache.Purge(t.Context()), "corrupt entries should be purged")
+		assert.Empty(t, entryFiles(t, dir))
+	})
+	t.Run("Purge", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache, err := NewDisk[int](DiskOptions{Dir: dir, Clock: clock})
+		require.NoError(t, err)
+		cache.Put(t.Context(), "short", 1, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "long", 2, clock.Now().Add(time.Hour))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated"), nil, 0600))
+
+		assert.Zero(t, cache.Purge(t.Context()))
+
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, 1, cache.Purge(t.Context()))
+		assert.Len(t, entryFiles(t, dir), 2, "only the expired entry should be removed")
+		val, ok := cache.Get(t.Context(), "long", 0)
+		require.True(t, ok)
+		assert.Equal(t, 2, val)
+	})
+	t.Run("PurgeKeepsUnreadableEntries", func(t *testing.T) {
+		dir := t.TempDir()
+		var errs []error
+		cache, err := NewDisk[int](DiskOptions{Dir: dir, OnError: func(err error) { errs = append(errs, err) }})
+		require.NoError(t, err)
+
+		// Reading a directory fails without the entry being corrupt.
+		target := filepath.Join(t.TempDir(), "target")
+		require.NoError(t, os.Mkdir(target, 0700))
+		unreadable := filepath.Join(dir, "unreadable"+diskEntryExtension)
+		require.NoError(t, os.Symlink(target, unreadable))
+
+		assert.Zero(t, cache.Purge(t.Context()))
+		_, err = os.Lstat(unreadable)
+		assert.NoError(t, err, "entries that cannot be read should not be removed")
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "reading entry")
+	})
+	t.Run("PurgeRemovesOldTemporaryFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := NewDisk[int](DiskOptions{Dir: dir})
+		require.NoError(t, err)
+		orphaned := filepath.Join(dir, diskTempPrefix+"orphaned")
+		recent := filepath.Join(dir, diskTempPrefix+"recent")
+		require.NoError(t, os.WriteFile(orphaned, []byte("partial"), 0600))
+		require.NoError(t, os.WriteFile(recent, []byte("partial"), 0600))
+		old := time.Now().Add(-2 * diskTempMaxAge)
+		require.NoError(t, os.Chtimes(orphaned, old, old))
+
+		assert.Zero(t, cache.Purge(t.Context()), "temporary files are not entries")
+		assert.NoFileExists(t, orphaned)
+		assert.FileExists(t, recent)
+	})
+	t.Run("ConcurrentWritesAreAtomic", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := NewDisk[[]int](DiskOptions{Dir: dir})
+		require.NoError(t, err)
+		expiresAt := time.Now().Add(time.Hour)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					value := make([]int, 100)
+					for k := range value {
+						value[k] = i
+					}
+					cache.Put(t.Context(), "key", value, expiresAt)
+					val, ok := cache.Get(t.Context(), "key", 0)
+					if assert.True(t, ok) && assert.Len(t, val, 100) {
+						for _, v := range val {
+							assert.Equal(t, val[0], v, "entry should never be partially written")
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, entryFiles(t, dir), 1, "temporary files should be cleaned up")
+	})
+}
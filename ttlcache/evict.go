@@ -0,0 +1,71 @@
+package ttlcache
+
+import "sync"
+
+// EvictionReason describes why an entry left a cache.
+type EvictionReason string
+
+const (
+	// EvictionExpired means that the entry expired and was purged.
+	EvictionExpired EvictionReason = "expired"
+	// EvictionDeleted means that the entry was deleted.
+	EvictionDeleted EvictionReason = "deleted"
+	// EvictionReplaced means that a new value was put with the entry's id.
+	EvictionReplaced EvictionReason = "replaced"
+	// EvictionCapacity means that the entry was evicted to make room for
+	// other entries.
+	EvictionCapacity EvictionReason = "capacity"
+	// EvictionCollected means that the entry's value was garbage collected.
+	EvictionCollected EvictionReason = "garbage-collected"
+)
+
+// EvictFunc is called with the id and value of an entry that left a cache,
+// along with the reason it left. It is called after the cache's lock is
+// released, so it may use the cache.
+type EvictFunc[T any] func(id string, value T, reason EvictionReason)
+
+// eviction is an entry that left a cache.
+type eviction[T any] struct {
+	id     string
+	value  T
+	reason EvictionReason
+}
+
+// evictListeners holds the callbacks registered with a cache's OnEvict
+// method. Caches collect evictions while they hold their lock and notify the
+// listeners once it is released.
+type evictListeners[T any] struct {
+	mu  sync.RWMutex
+	fns []EvictFunc[T]
+}
+
+func (l *evictListeners[T]) add(fn EvictFunc[T]) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fns = append(l.fns, fn)
+}
+
+// enabled returns whether there are any listeners, so that caches can avoid
+// collecting evictions that nobody is listening for.
+func (l *evictListeners[T]) enabled() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.fns) > 0
+}
+
+func (l *evictListeners[T]) notify(evictions []eviction[T]) {
+	if len(evictions) == 0 {
+		return
+	}
+
+	l.mu.RLock()
+	fns := l.fns
+	l.mu.RUnlock()
+	for _, e := range evictions {
+		for _, fn := range fns {
+			fn(e.id, e.value, e.reason)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package ttlcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LoaderFunc loads a value that was not found in the cache, returning the value
+// and the time at which it expires.
+type LoaderFunc[T any] func(ctx context.Context) (T, time.Time, error)
+
+// WithLoading wraps a cache so that values missing from it can be loaded with
+// GetOrLoad.
+func WithLoading[T any](cache Cache[T]) *LoadingCache[T] {
+	return &LoadingCache[T]{
+		cache: cache,
+		calls: make(map[string]*loadCall[T]),
+	}
+}
+
+type LoadingCache[T any] struct {
+	cache Cache[T]
+
+	mu    sync.Mutex
+	calls map[string]*loadCall[T]
+}
+
+// loadCall is an in-progress load of a value shared by every caller waiting on
+// it.
+type loadCall[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// GetOrLoad gets the value with id with at least the minimum lifetime
+// remaining. If there is no such value, it loads the value, adds it to the
+// cache and returns it.
+//
+// Concurrent calls for the same id share a single call to the loader. The load
+// is canceled only once every caller waiting on it has given up, so a caller
+// whose context is done does not fail the others. Errors from the loader,
+// including panics, are returned to every waiting caller and are not cached.
+func (c *LoadingCache[T]) GetOrLoad(ctx context.Context, id string, minimumLifetime time.Duration, loader LoaderFunc[T]) (T, error) {
+	if value, ok := c.cache.Get(ctx, id, minimumLifetime); ok {
+		return value, nil
+	}
+	return c.load(ctx, id, loader)
+}
+
+// load loads the value with id and adds it to the cache, joining the load
+// already in progress for the id if there is one.
+func (c *LoadingCache[T]) load(ctx context.Context, id string, loader LoaderFunc[T]) (T, error) {
+	call := c.start(ctx, id, loader)
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody is waiting on the load anymore, so later callers must
+			// start a new one rather than join a canceled one.
+			call.cancel()
+			if c.calls[id] == call {
+				delete(c.calls, id)
+			}
+		}
+		c.mu.Unlock()
+
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// start starts loading the value with id, or joins the load already in
+// progress for the id, and returns the load. The caller is counted as waiting
+// on the load until it is done.
+func (c *LoadingCache[T]) start(ctx context.Context, id string, loader LoaderFunc[T]) *loadCall[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	call, ok := c.calls[id]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &loadCall[T]{done: make(chan struct{}), cancel: cancel}
+		c.calls[id] = call
+		go c.run(loadCtx, id, call, loader)
+	}
+	call.waiters++
+	return call
+}
+
+// run calls the loader for a load and shares its result with the callers
+// waiting on it.
+func (c *LoadingCache[T]) run(ctx context.Context, id string, call *loadCall[T], loader LoaderFunc[T]) {
+	defer call.cancel()
+
+	value, expiresAt, err := callLoader(ctx, loader)
+	if err == nil {
+		c.cache.Put(ctx, id, value, expiresAt)
+	}
+
+	c.mu.Lock()
+	if c.calls[id] == call {
+		delete(c.calls, id)
+	}
+	c.mu.Unlock()
+
+	call.value = value
+	call.err = err
+	close(call.done)
+}
+
+// callLoader calls the loader, returning an error if it panics so that the
+// panic fails the load rather than the process.
+func callLoader[T any](ctx context.Context, loader LoaderFunc[T]) (value T, expiresAt time.Time, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			value, expiresAt, err = zero, time.Time{}, errors.Errorf("loader panicked: %v", r)
+		}
+	}()
+	return loader(ctx)
+}
+
+func (c *LoadingCache[T]) Get(ctx context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	return c.cache.Get(ctx, id, minimumLifetime)
+}
+
+func (c *LoadingCache[T]) Put(ctx context.Context, id string, value T, expiresAt time.Time) {
+	c.cache.Put(ctx, id, value, expiresAt)
+}
+
+func (c *LoadingCache[T]) Delete(ctx context.Context, id string) {
+	c.cache.Delete(ctx, id)
+}
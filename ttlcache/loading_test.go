@@ -0,0 +1,220 @@
+package ttlcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache(t *testing.T) {
+	testCache(t, func() Cache[*int] {
+		return WithLoading[*int](NewInMemory[*int]())
+	})
+
+	// numWaiters returns the number of callers waiting on the load of id.
+	numWaiters := func(cache *LoadingCache[int], id string) int {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if call, ok := cache.calls[id]; ok {
+			return call.waiters
+		}
+		return 0
+	}
+
+	t.Run("ReturnsCachedValue", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		cache.Put(t.Context(), "key", 22, time.Now().Add(time.Hour))
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, func(context.Context) (int, time.Time, error) {
+			return 0, time.Time{}, errors.New("loader should not be called")
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 22, val)
+	})
+	t.Run("LoadsMissingValue", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		loader := func(context.Context) (int, time.Time, error) {
+			return 22, time.Now().Add(time.Hour), nil
+		}
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 22, val)
+
+		val, ok := cache.Get(t.Context(), "key", time.Minute)
+		require.True(t, ok, "loaded value should be cached")
+		assert.Equal(t, 22, val)
+		assert.Empty(t, cache.calls)
+	})
+	t.Run("ReloadsValueWithoutMinimumLifetime", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		cache.Put(t.Context(), "key", 22, time.Now().Add(time.Minute))
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Hour, func(context.Context) (int, time.Time, error) {
+			return 23, time.Now().Add(2 * time.Hour), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 23, val)
+	})
+	t.Run("DoesNotCacheErrors", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		var calls int
+		loader := func(context.Context) (int, time.Time, error) {
+			calls++
+			if calls == 1 {
+				return 0, time.Time{}, errors.New("something went wrong")
+			}
+			return 22, time.Now().Add(time.Hour), nil
+		}
+
+		_, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		assert.EqualError(t, err, "something went wrong")
+		_, ok := cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok)
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 22, val)
+		assert.Equal(t, 2, calls)
+	})
+	t.Run("DeduplicatesConcurrentLoads", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		release := make(chan struct{})
+		var calls atomic.Int32
+		loader := func(context.Context) (int, time.Time, error) {
+			calls.Add(1)
+			<-release
+			return 22, time.Now().Add(time.Hour), nil
+		}
+
+		const numCallers = 20
+		var wg sync.WaitGroup
+		for i := 0; i < numCallers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+				assert.NoError(t, err)
+				assert.Equal(t, 22, val)
+			}()
+		}
+
+		require.Eventually(t, func() bool { return numWaiters(cache, "key") == numCallers }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.EqualValues(t, 1, calls.Load())
+	})
+	t.Run("SharesErrors", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		release := make(chan struct{})
+		loader := func(context.Context) (int, time.Time, error) {
+			<-release
+			return 0, time.Time{}, errors.New("something went wrong")
+		}
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+				errs <- err
+			}()
+		}
+
+		require.Eventually(t, func() bool { return numWaiters(cache, "key") == 2 }, time.Second, time.Millisecond)
+		close(release)
+		assert.EqualError(t, <-errs, "something went wrong")
+		assert.EqualError(t, <-errs, "something went wrong")
+	})
+	t.Run("RecoversPanickingLoader", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		_, err := cache.GetOrLoad(t.Context(), "key", time.Minute, func(context.Context) (int, time.Time, error) {
+			panic("something went wrong")
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "loader panicked: something went wrong")
+		assert.Zero(t, numWaiters(cache, "key"))
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, func(context.Context) (int, time.Time, error) {
+			return 22, time.Now().Add(time.Hour), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 22, val)
+	})
+	t.Run("CanceledCallerDoesNotCancelOthers", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		release := make(chan struct{})
+		loader := func(ctx context.Context) (int, time.Time, error) {
+			select {
+			case <-release:
+				return 22, time.Now().Add(time.Hour), nil
+			case <-ctx.Done():
+				return 0, time.Time{}, ctx.Err()
+			}
+		}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		canceledErr := make(chan error)
+		go func() {
+			_, err := cache.GetOrLoad(ctx, "key", time.Minute, loader)
+			canceledErr <- err
+		}()
+		require.Eventually(t, func() bool { return numWaiters(cache, "key") == 1 }, time.Second, time.Millisecond)
+
+		type result struct {
+			val int
+			err error
+		}
+		results := make(chan result)
+		go func() {
+			val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+			results <- result{val: val, err: err}
+		}()
+		require.Eventually(t, func() bool { return numWaiters(cache, "key") == 2 }, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceledErr, context.Canceled)
+
+		close(release)
+		res := <-results
+		require.NoError(t, res.err)
+		assert.Equal(t, 22, res.val)
+	})
+	t.Run("CancelsLoadWhenAllCallersGiveUp", func(t *testing.T) {
+		cache := WithLoading[int](NewInMemory[int]())
+		loadCanceled := make(chan struct{})
+		ctx, cancel := context.WithCancel(t.Context())
+
+		errs := make(chan error)
+		go func() {
+			_, err := cache.GetOrLoad(ctx, "key", time.Minute, func(ctx context.Context) (int, time.Time, error) {
+				<-ctx.Done()
+				close(loadCanceled)
+				return 0, time.Time{}, ctx.Err()
+			})
+			errs <- err
+		}()
+		require.Eventually(t, func() bool { return numWaiters(cache, "key") == 1 }, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+		select {
+		case <-loadCanceled:
+		case <-time.After(time.Second):
+			assert.Fail(t, "load should be canceled once no callers are waiting")
+		}
+
+		t.Run("LaterCallersStartNewLoad", func(t *testing.T) {
+			val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, func(context.Context) (int, time.Time, error) {
+				return 22, time.Now().Add(time.Hour), nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 22, val)
+		})
+	})
+}
@@ -2,21 +2,65 @@ package ttlcache
 
 import (
 	"context"
+	"iter"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/evergreen-ci/utility"
 )
 
+// InMemoryOptions configure an in-memory ttl cache.
+type InMemoryOptions struct {
+	// Clock is used to determine how long entries have left before they
+	// expire. By default, it is the system clock.
+	Clock utility.Clock
+	// CleanupInterval, if set, starts a janitor that purges expired entries
+	// at this interval until the cache is closed. See StartJanitor.
+	CleanupInterval time.Duration
+}
+
+// Validate sets defaults for unspecified options.
+func (o *InMemoryOptions) Validate() {
+	if o.Clock == nil {
+		o.Clock = utility.RealClock{}
+	}
+}
+
 // NewInMemory creates a new thread-safe in-memory ttl cache.
 func NewInMemory[T any]() *InMemoryCache[T] {
-	return &InMemoryCache[T]{
-		mu:    sync.RWMutex{},
-		cache: make(map[string]ttlValue[T]),
+	return NewInMemoryWithOptions[T](InMemoryOptions{})
+}
+
+// NewInMemoryWithOptions creates a new thread-safe in-memory ttl cache with
+// the given options.
+func NewInMemoryWithOptions[T any](opts InMemoryOptions) *InMemoryCache[T] {
+	opts.Validate()
+	c := &InMemoryCache[T]{
+		mu:     sync.RWMutex{},
+		cache:  make(map[string]ttlValue[T]),
+		clock:  opts.Clock,
+		closed: make(chan struct{}),
 	}
+	if opts.CleanupInterval > 0 {
+		c.StartJanitor(context.Background(), opts.CleanupInterval)
+	}
+	return c
 }
 
 type InMemoryCache[T any] struct {
 	mu    sync.RWMutex
 	cache map[string]ttlValue[T]
+	clock utility.Clock
+
+	listeners evictListeners[T]
+	// evicted holds the evictions made while the lock is held, which are
+	// passed to the listeners once it is released.
+	evicted []eviction[T]
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	janitors  sync.WaitGroup
 }
 
 func (c *InMemoryCache[T]) Get(_ context.Context, id string, minimumLifetime time.Duration) (T, bool) {
@@ -28,7 +72,7 @@ func (c *InMemoryCache[T]) Get(_ context.Context, id string, minimumLifetime tim
 		var value T
 		return value, false
 	}
-	if time.Until(cachedToken.expiresAt) < minimumLifetime {
+	if cachedToken.expiresAt.Sub(c.clock.Now()) < minimumLifetime {
 		var value T
 		return value, false
 	}
@@ -38,8 +82,11 @@ func (c *InMemoryCache[T]) Get(_ context.Context, id string, minimumLifetime tim
 
 func (c *InMemoryCache[T]) Put(_ context.Context, id string, value T, expiresAt time.Time) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if old, ok := c.cache[id]; ok {
+		c.evict(id, old.value, EvictionReplaced)
+	}
 	c.cache[id] = ttlValue[T]{
 		value:     value,
 		expiresAt: expiresAt,
@@ -48,7 +95,201 @@ func (c *InMemoryCache[T]) Put(_ context.Context, id string, value T, expiresAt
 
 func (c *InMemoryCache[T]) Delete(_ context.Context, id string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+
+	if old, ok := c.cache[id]; ok {
+		c.evict(id, old.value, EvictionDeleted)
+		delete(c.cache, id)
+	}
+}
+
+// GetMany gets the values with the given ids that have at least the minimum
+// lifetime remaining.
+func (c *InMemoryCache[T]) GetMany(_ context.Context, ids []string, minimumLifetime time.Duration) map[string]T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now()
+	values := make(map[string]T, len(ids))
+	for _, id := range ids {
+		if cachedToken, ok := c.cache[id]; ok && cachedToken.expiresAt.Sub(now) >= minimumLifetime {
+			values[id] = cachedToken.value
+		}
+	}
+	return values
+}
+
+// PutMany adds the entries to the cache, keyed by id.
+func (c *InMemoryCache[T]) PutMany(_ context.Context, entries map[string]Entry[T]) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	for id, entry := range entries {
+		if old, ok := c.cache[id]; ok {
+			c.evict(id, old.value, EvictionReplaced)
+		}
+		c.cache[id] = ttlValue[T]{
+			value:     entry.Value,
+			expiresAt: entry.ExpiresAt,
+		}
+	}
+}
+
+// DeleteMany removes the values with the given ids from the cache.
+func (c *InMemoryCache[T]) DeleteMany(_ context.Context, ids []string) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	for _, id := range ids {
+		if old, ok := c.cache[id]; ok {
+			c.evict(id, old.value, EvictionDeleted)
+			delete(c.cache, id)
+		}
+	}
+}
+
+// Clear removes every entry from the cache. The entries are reported to the
+// eviction listeners as deleted.
+func (c *InMemoryCache[T]) Clear(_ context.Context) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	for id, cachedToken := range c.cache {
+		c.evict(id, cachedToken.value, EvictionDeleted)
+	}
+	c.cache = make(map[string]ttlValue[T])
+}
+
+// Keys returns the ids of the entries that have not expired, in sorted order.
+func (c *InMemoryCache[T]) Keys(ctx context.Context) []string {
+	var ids []string
+	for id := range c.All(ctx) {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// All returns an iterator over the entries that have not expired, keyed by
+// id. The entries are read when iteration starts, so the cache may be used
+// while iterating.
+func (c *InMemoryCache[T]) All(_ context.Context) iter.Seq2[string, LiveEntry[T]] {
+	return func(yield func(string, LiveEntry[T]) bool) {
+		for id, entry := range c.live() {
+			if !yield(id, entry) {
+				return
+			}
+		}
+	}
+}
+
+// live returns the entries that have not expired along with their remaining
+// lifetimes.
+func (c *InMemoryCache[T]) live() map[string]LiveEntry[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now()
+	entries := make(map[string]LiveEntry[T], len(c.cache))
+	for id, cachedToken := range c.cache {
+		if !cachedToken.expired(now) {
+			entries[id] = LiveEntry[T]{Value: cachedToken.value, TTL: cachedToken.expiresAt.Sub(now)}
+		}
+	}
+	return entries
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not been purged.
+func (c *InMemoryCache[T]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.cache)
+}
+
+// Purge removes every expired entry from the cache and returns the number of
+// entries removed. Expired entries are otherwise only removed when they are
+// deleted or replaced.
+func (c *InMemoryCache[T]) Purge(_ context.Context) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.clock.Now()
+	var purged int
+	for id, cachedToken := range c.cache {
+		if cachedToken.expired(now) {
+			c.evict(id, cachedToken.value, EvictionExpired)
+			delete(c.cache, id)
+			purged++
+		}
+	}
+	return purged
+}
+
+// OnEvict registers a function to call when an entry leaves the cache because
+// it was replaced, deleted, or purged after it expired.
+func (c *InMemoryCache[T]) OnEvict(fn EvictFunc[T]) {
+	c.listeners.add(fn)
+}
+
+// evict records that an entry left the cache. It must be called with the lock
+// held.
+func (c *InMemoryCache[T]) evict(id string, value T, reason EvictionReason) {
+	if c.listeners.enabled() {
+		c.evicted = append(c.evicted, eviction[T]{id: id, value: value, reason: reason})
+	}
+}
+
+// unlock releases the lock and then notifies the listeners of the evictions
+// made while it was held.
+func (c *InMemoryCache[T]) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.mu.Unlock()
+
+	c.listeners.notify(evicted)
+}
+
+// StartJanitor starts a goroutine that purges expired entries at the given
+// interval, as measured by the cache's clock, until the context is done or
+// the cache is closed. It does nothing if the interval is not positive.
+func (c *InMemoryCache[T]) StartJanitor(ctx context.Context, interval time.Duration) {
+	c.startJanitor(ctx, interval, c.Purge)
+}
+
+// startJanitor starts a goroutine that calls purge at the given interval
+// until the context is done or the cache is closed. A non-positive interval
+// would purge continuously, so no goroutine is started for it.
+func (c *InMemoryCache[T]) startJanitor(ctx context.Context, interval time.Duration, purge func(context.Context) int) {
+	if interval <= 0 {
+		return
+	}
+
+	c.janitors.Add(1)
+	go func() {
+		defer c.janitors.Done()
+
+		timer := c.clock.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.closed:
+				return
+			case <-timer.C():
+				purge(ctx)
+				timer.Reset(interval)
+			}
+		}
+	}()
+}
 
-	delete(c.cache, id)
+// Close stops any janitors and waits for them to exit. The cache can still be
+// used after it is closed.
+func (c *InMemoryCache[T]) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	c.janitors.Wait()
+	return nil
 }
@@ -1,11 +1,149 @@
 package ttlcache
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTTLInMemoryCache(t *testing.T) {
 	testCache(t, func() Cache[*int] {
 		return NewInMemory[*int]()
 	})
+
+	t.Run("OnEvict", func(t *testing.T) {
+		testOnEvict(t, func(clock utility.Clock) evictingCache {
+			return NewInMemoryWithOptions[*int](InMemoryOptions{Clock: clock})
+		})
+	})
+
+	t.Run("BulkCache", func(t *testing.T) {
+		testBulkCache(t, func(clock utility.Clock) BulkCache[*int] {
+			return NewInMemoryWithOptions[*int](InMemoryOptions{Clock: clock})
+		})
+	})
+	t.Run("ClearReportsEvictions", func(t *testing.T) {
+		cache := NewInMemory[int]()
+		var evicted []string
+		cache.OnEvict(func(id string, _ int, reason EvictionReason) {
+			assert.Equal(t, EvictionDeleted, reason)
+			evicted = append(evicted, id)
+		})
+		cache.PutMany(t.Context(), map[string]Entry[int]{
+			"a": {Value: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			"b": {Value: 2, ExpiresAt: time.Now().Add(time.Hour)},
+		})
+		cache.Clear(t.Context())
+		assert.ElementsMatch(t, []string{"a", "b"}, evicted)
+	})
+
+	t.Run("UsesClock", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := NewInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		val, ok := cache.Get(t.Context(), "key", time.Hour)
+		require.True(t, ok)
+		assert.Equal(t, 22, val)
+
+		clock.Advance(time.Minute)
+		_, ok = cache.Get(t.Context(), "key", time.Hour)
+		assert.False(t, ok)
+		_, ok = cache.Get(t.Context(), "key", 59*time.Minute)
+		assert.True(t, ok)
+
+		clock.Advance(time.Hour)
+		_, ok = cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := NewInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+		cache.Put(t.Context(), "short", 1, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "long", 2, clock.Now().Add(time.Hour))
+
+		assert.Zero(t, cache.Purge(t.Context()))
+
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, 1, cache.Purge(t.Context()))
+		assert.Len(t, cache.cache, 1)
+		val, ok := cache.Get(t.Context(), "long", 0)
+		require.True(t, ok)
+		assert.Equal(t, 2, val)
+	})
+	t.Run("Janitor", func(t *testing.T) {
+		newCache := func(t *testing.T) (*InMemoryCache[int], *utility.FakeClock) {
+			clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+			cache := NewInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+			cache.Put(t.Context(), "short", 1, clock.Now().Add(time.Minute))
+			cache.Put(t.Context(), "long", 2, clock.Now().Add(time.Hour))
+			return cache, clock
+		}
+		numEntries := func(cache *InMemoryCache[int]) int {
+			cache.mu.RLock()
+			defer cache.mu.RUnlock()
+			return len(cache.cache)
+		}
+
+		t.Run("PurgesAtInterval", func(t *testing.T) {
+			cache, clock := newCache(t)
+			cache.StartJanitor(t.Context(), time.Minute)
+			defer cache.Close()
+
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+			clock.Advance(time.Minute)
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+			assert.Equal(t, 2, numEntries(cache), "entry should not be purged at its expiration time")
+
+			clock.Advance(time.Minute)
+			assert.Eventually(t, func() bool { return numEntries(cache) == 1 }, time.Second, time.Millisecond)
+		})
+		t.Run("StartsFromOptions", func(t *testing.T) {
+			clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+			cache := NewInMemoryWithOptions[int](InMemoryOptions{Clock: clock, CleanupInterval: time.Hour})
+			defer cache.Close()
+			cache.Put(t.Context(), "short", 1, clock.Now().Add(time.Minute))
+
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+			clock.Advance(time.Hour)
+			assert.Eventually(t, func() bool { return numEntries(cache) == 0 }, time.Second, time.Millisecond)
+		})
+		t.Run("IgnoresNonPositiveInterval", func(t *testing.T) {
+			cache, clock := newCache(t)
+			cache.StartJanitor(t.Context(), 0)
+			cache.StartJanitor(t.Context(), -time.Minute)
+			defer cache.Close()
+
+			assert.Zero(t, clock.Waiters(), "no janitor should be started")
+			clock.Advance(time.Hour)
+			assert.Equal(t, 2, numEntries(cache))
+		})
+		t.Run("StopsOnClose", func(t *testing.T) {
+			cache, clock := newCache(t)
+			cache.StartJanitor(t.Context(), time.Minute)
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+
+			require.NoError(t, cache.Close())
+			assert.Zero(t, clock.Waiters())
+			clock.Advance(time.Hour)
+			assert.Equal(t, 2, numEntries(cache))
+		})
+		t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+			cache, clock := newCache(t)
+			ctx, cancel := context.WithCancel(t.Context())
+			cache.StartJanitor(ctx, time.Minute)
+			require.NoError(t, clock.BlockUntil(t.Context(), 1))
+
+			cancel()
+			assert.Eventually(t, func() bool { return clock.Waiters() == 0 }, time.Second, time.Millisecond)
+			clock.Advance(time.Hour)
+			assert.Equal(t, 2, numEntries(cache))
+			require.NoError(t, cache.Close())
+		})
+	})
 }
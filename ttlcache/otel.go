@@ -3,13 +3,19 @@ package ttlcache
 import (
 	"context"
 	"fmt"
+	"iter"
+	"slices"
 	"time"
 
+	"github.com/evergreen-ci/utility"
+	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var tracer = otel.GetTracerProvider().Tracer("github.com/evergreen-ci/utility/cache")
+const cacheInstrumentationName = "github.com/evergreen-ci/utility/cache"
 
 const (
 	ttlCacheAttribute = "evergreen.cache.ttl"
@@ -18,56 +24,367 @@ const (
 var (
 	ttlCacheNameAttribute  = fmt.Sprintf("%s.name", ttlCacheAttribute)
 	ttlCacheIDAttribute    = fmt.Sprintf("%s.id", ttlCacheAttribute)
+	ttlCacheIDsAttribute   = fmt.Sprintf("%s.ids", ttlCacheAttribute)
 	ttlCacheFoundAttribute = fmt.Sprintf("%s.found", ttlCacheAttribute)
+	ttlCacheCountAttribute = fmt.Sprintf("%s.count", ttlCacheAttribute)
 )
 
-// WithOtel wraps a cache and adds OpenTelemetry tracing to it.
-// Since this tracks the id, do not use this if the id is sensitive.
-// This can be safely used with sensitive values.
+// Names of the metrics recorded by OtelCache.
+const (
+	CacheHitsMetric    = "evergreen.cache.ttl.hits"
+	CacheMissesMetric  = "evergreen.cache.ttl.misses"
+	CachePutsMetric    = "evergreen.cache.ttl.puts"
+	CacheDeletesMetric = "evergreen.cache.ttl.deletes"
+	CacheEntriesMetric = "evergreen.cache.ttl.entries"
+)
+
+// IDAttributeMode determines how ids are recorded in span attributes.
+type IDAttributeMode string
+
+const (
+	// IDAttributePlain records ids as they are.
+	IDAttributePlain IDAttributeMode = "plain"
+	// IDAttributeHashed records the SHA-256 hash of ids, so that operations on
+	// the same id can be correlated without exposing it. Ids that are easy to
+	// guess, such as sequential user ids, can still be recovered from their
+	// hashes.
+	IDAttributeHashed IDAttributeMode = "hashed"
+	// IDAttributeOmitted does not record ids.
+	IDAttributeOmitted IDAttributeMode = "omitted"
+)
+
+// OtelOptions configure an OtelCache.
+type OtelOptions struct {
+	// Name identifies the cache in its spans and metrics.
+	Name string
+	// IDAttribute determines how ids are recorded in span attributes. By
+	// default, it is IDAttributePlain.
+	IDAttribute IDAttributeMode
+	// MeterProvider provides the meter for the cache's metrics. By default,
+	// it is the global meter provider.
+	MeterProvider metric.MeterProvider
+	// ReportEntries, if set, reports the number of entries in the cache with
+	// a gauge, which requires the cache to have a Len method. The gauge's
+	// callback keeps the cache reachable until the OtelCache is closed, so
+	// Close must be called once the cache is no longer used.
+	ReportEntries bool
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *OtelOptions) Validate() error {
+	if o.IDAttribute == "" {
+		o.IDAttribute = IDAttributePlain
+	}
+	if o.MeterProvider == nil {
+		o.MeterProvider = otel.GetMeterProvider()
+	}
+
+	switch o.IDAttribute {
+	case IDAttributePlain, IDAttributeHashed, IDAttributeOmitted:
+		return nil
+	default:
+		return errors.Errorf("unknown id attribute mode '%s'", o.IDAttribute)
+	}
+}
+
+// WithOtel wraps a cache and adds OpenTelemetry tracing and metrics to it.
+// Since this tracks the id, do not use this if the id is sensitive; use
+// WithOtelOptions to hash or omit it instead.
+// This can be safely used with sensitive values, which are never recorded.
 func WithOtel[T any](cache Cache[T], name string) *OtelCache[T] {
-	return &OtelCache[T]{cache: cache, name: name}
+	c, err := WithOtelOptions(cache, OtelOptions{Name: name})
+	if err != nil {
+		// The default options are valid, so this can only fail to create the
+		// metrics, in which case the cache is still traced.
+		otel.Handle(err)
+		return &OtelCache[T]{cache: cache, opts: OtelOptions{Name: name, IDAttribute: IDAttributePlain}}
+	}
+	return c
+}
+
+// WithOtelOptions wraps a cache and adds OpenTelemetry tracing and metrics to
+// it.
+//
+// Use WithOtelBulk to wrap a BulkCache so that the wrapped cache is also a
+// BulkCache.
+func WithOtelOptions[T any](cache Cache[T], opts OtelOptions) (*OtelCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	c := &OtelCache[T]{
+		cache:      cache,
+		opts:       opts,
+		attributes: metric.WithAttributes(attribute.String(ttlCacheNameAttribute, opts.Name)),
+	}
+	if err := c.registerMetrics(); err != nil {
+		return nil, errors.Wrap(err, "registering metrics")
+	}
+	return c, nil
 }
 
+// OtelCache is a cache that records OpenTelemetry spans and metrics for its
+// operations.
 type OtelCache[T any] struct {
 	cache Cache[T]
-	name  string
+	opts  OtelOptions
+
+	attributes   metric.MeasurementOption
+	hits         metric.Int64Counter
+	misses       metric.Int64Counter
+	puts         metric.Int64Counter
+	deletes      metric.Int64Counter
+	registration metric.Registration
+}
+
+// lenCache is a cache that can report its number of entries.
+type lenCache interface {
+	Len() int
+}
+
+func (c *OtelCache[T]) registerMetrics() error {
+	meter := c.opts.MeterProvider.Meter(cacheInstrumentationName)
+
+	var err error
+	c.hits, err = meter.Int64Counter(CacheHitsMetric,
+		metric.WithDescription("Number of cache lookups that found a value."),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", CacheHitsMetric)
+	}
+	c.misses, err = meter.Int64Counter(CacheMissesMetric,
+		metric.WithDescription("Number of cache lookups that did not find a value."),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", CacheMissesMetric)
+	}
+	c.puts, err = meter.Int64Counter(CachePutsMetric,
+		metric.WithDescription("Number of values added to the cache."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", CachePutsMetric)
+	}
+	c.deletes, err = meter.Int64Counter(CacheDeletesMetric,
+		metric.WithDescription("Number of values deleted from the cache."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s counter", CacheDeletesMetric)
+	}
+
+	if !c.opts.ReportEntries {
+		return nil
+	}
+	lc, ok := c.cache.(lenCache)
+	if !ok {
+		return errors.New("cache must have a Len method to report its number of entries")
+	}
+	entriesGauge, err := meter.Int64ObservableGauge(CacheEntriesMetric,
+		metric.WithDescription("Number of entries in the cache."),
+		metric.WithUnit("{entry}"),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s gauge", CacheEntriesMetric)
+	}
+	c.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(entriesGauge, int64(lc.Len()), c.attributes)
+		return nil
+	}, entriesGauge)
+	return errors.Wrap(err, "registering gauge callback")
+}
+
+// tracer returns the cache tracer from the global tracer provider, which is
+// looked up each time so that changes to the global provider take effect.
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(cacheInstrumentationName)
+}
+
+// idAttributes returns the span attributes for the id.
+func (c *OtelCache[T]) idAttributes(id string) []attribute.KeyValue {
+	switch c.opts.IDAttribute {
+	case IDAttributeHashed:
+		h := utility.NewSHA256Hash()
+		h.Add(id)
+		return []attribute.KeyValue{attribute.String(ttlCacheIDAttribute, h.Sum())}
+	case IDAttributeOmitted:
+		return nil
+	default:
+		return []attribute.KeyValue{attribute.String(ttlCacheIDAttribute, id)}
+	}
+}
+
+// idsAttributes returns the span attributes for the ids.
+func (c *OtelCache[T]) idsAttributes(ids []string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.Int(ttlCacheCountAttribute, len(ids))}
+	switch c.opts.IDAttribute {
+	case IDAttributeHashed:
+		hashed := make([]string, 0, len(ids))
+		for _, id := range ids {
+			h := utility.NewSHA256Hash()
+			h.Add(id)
+			hashed = append(hashed, h.Sum())
+		}
+		return append(attrs, attribute.StringSlice(ttlCacheIDsAttribute, hashed))
+	case IDAttributeOmitted:
+		return attrs
+	default:
+		return append(attrs, attribute.StringSlice(ttlCacheIDsAttribute, ids))
+	}
+}
+
+// add adds n to the counter if it exists.
+func (c *OtelCache[T]) add(ctx context.Context, counter metric.Int64Counter, n int) {
+	if counter != nil && n > 0 {
+		counter.Add(ctx, int64(n), c.attributes)
+	}
 }
 
 func (c *OtelCache[T]) Get(ctx context.Context, id string, minimumLifetime time.Duration) (T, bool) {
-	ctx, span := tracer.Start(ctx, "cache.Get")
+	ctx, span := tracer().Start(ctx, "cache.Get")
 	defer span.End()
 
 	value, ok := c.cache.Get(ctx, id, minimumLifetime)
 
-	span.SetAttributes(
-		attribute.String(ttlCacheNameAttribute, c.name),
-		attribute.String(ttlCacheIDAttribute, id),
+	span.SetAttributes(append(c.idAttributes(id),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
 		attribute.Bool(ttlCacheFoundAttribute, ok),
-	)
+	)...)
+	if ok {
+		c.add(ctx, c.hits, 1)
+	} else {
+		c.add(ctx, c.misses, 1)
+	}
 
 	return value, ok
 }
 
 func (c *OtelCache[T]) Put(ctx context.Context, id string, value T, expiresAt time.Time) {
-	ctx, span := tracer.Start(ctx, "cache.Put")
+	ctx, span := tracer().Start(ctx, "cache.Put")
 	defer span.End()
 
-	span.SetAttributes(
-		attribute.String(ttlCacheNameAttribute, c.name),
-		attribute.String(ttlCacheIDAttribute, id),
-	)
+	span.SetAttributes(append(c.idAttributes(id),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+	)...)
 
 	c.cache.Put(ctx, id, value, expiresAt)
+	c.add(ctx, c.puts, 1)
 }
 
 func (c *OtelCache[T]) Delete(ctx context.Context, id string) {
-	ctx, span := tracer.Start(ctx, "cache.Delete")
+	ctx, span := tracer().Start(ctx, "cache.Delete")
 	defer span.End()
 
+	span.SetAttributes(append(c.idAttributes(id),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+	)...)
+
+	c.cache.Delete(ctx, id)
+	c.add(ctx, c.deletes, 1)
+}
+
+// Close stops reporting the number of entries in the cache, which must be done
+// once the cache is no longer used if ReportEntries is set. The cache can
+// still be used after it is closed.
+func (c *OtelCache[T]) Close() error {
+	if c.registration == nil {
+		return nil
+	}
+	return errors.Wrap(c.registration.Unregister(), "unregistering metrics callback")
+}
+
+// WithOtelBulk wraps a bulk cache and adds OpenTelemetry tracing and metrics
+// to it, including to its bulk operations.
+func WithOtelBulk[T any](cache BulkCache[T], opts OtelOptions) (*BulkOtelCache[T], error) {
+	c, err := WithOtelOptions[T](cache, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BulkOtelCache[T]{OtelCache: c, cache: cache}, nil
+}
+
+// BulkOtelCache is an OtelCache for a BulkCache, which also records spans and
+// metrics for the bulk operations.
+type BulkOtelCache[T any] struct {
+	*OtelCache[T]
+	cache BulkCache[T]
+}
+
+func (c *BulkOtelCache[T]) GetMany(ctx context.Context, ids []string, minimumLifetime time.Duration) map[string]T {
+	ctx, span := tracer().Start(ctx, "cache.GetMany")
+	defer span.End()
+
+	values := c.cache.GetMany(ctx, ids, minimumLifetime)
+
+	span.SetAttributes(append(c.idsAttributes(ids),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+	)...)
+	c.add(ctx, c.hits, len(values))
+	c.add(ctx, c.misses, len(ids)-len(values))
+
+	return values
+}
+
+func (c *BulkOtelCache[T]) PutMany(ctx context.Context, entries map[string]Entry[T]) {
+	ctx, span := tracer().Start(ctx, "cache.PutMany")
+	defer span.End()
+
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	span.SetAttributes(append(c.idsAttributes(ids),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+	)...)
+
+	c.cache.PutMany(ctx, entries)
+	c.add(ctx, c.puts, len(entries))
+}
+
+func (c *BulkOtelCache[T]) DeleteMany(ctx context.Context, ids []string) {
+	ctx, span := tracer().Start(ctx, "cache.DeleteMany")
+	defer span.End()
+
+	span.SetAttributes(append(c.idsAttributes(ids),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+	)...)
+
+	c.cache.DeleteMany(ctx, ids)
+	c.add(ctx, c.deletes, len(ids))
+}
+
+// Len returns the number of entries in the underlying cache.
+func (c *BulkOtelCache[T]) Len() int {
+	return c.cache.Len()
+}
+
+// Clear removes every entry from the underlying cache.
+func (c *BulkOtelCache[T]) Clear(ctx context.Context) {
+	ctx, span := tracer().Start(ctx, "cache.Clear")
+	defer span.End()
+
+	n := c.cache.Len()
+	c.cache.Clear(ctx)
+
 	span.SetAttributes(
-		attribute.String(ttlCacheNameAttribute, c.name),
-		attribute.String(ttlCacheIDAttribute, id),
+		attribute.String(ttlCacheNameAttribute, c.opts.Name),
+		attribute.Int(ttlCacheCountAttribute, n),
 	)
+	c.add(ctx, c.deletes, n)
+}
 
-	c.cache.Delete(ctx, id)
+// Keys returns the ids of the entries in the underlying cache that have not
+// expired, in sorted order.
+func (c *BulkOtelCache[T]) Keys(ctx context.Context) []string {
+	return c.cache.Keys(ctx)
+}
+
+// All returns an iterator over the entries in the underlying cache that have
+// not expired.
+func (c *BulkOtelCache[T]) All(ctx context.Context) iter.Seq2[string, LiveEntry[T]] {
+	return c.cache.All(ctx)
 }
@@ -2,10 +2,227 @@ package ttlcache
 
 import (
 	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestTTLOtelCache(t *testing.T) {
 	testCache(t, func() Cache[*int] {
 		return WithOtel(NewInMemory[*int](), "test")
 	})
+
+	t.Run("BulkCache", func(t *testing.T) {
+		testBulkCache(t, func(clock utility.Clock) BulkCache[*int] {
+			cache, err := WithOtelBulk[*int](NewInMemoryWithOptions[*int](InMemoryOptions{Clock: clock}), OtelOptions{Name: "test"})
+			require.NoError(t, err)
+			return cache
+		})
+	})
+}
+
+func TestOtelOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		var opts OtelOptions
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, IDAttributePlain, opts.IDAttribute)
+		assert.NotNil(t, opts.MeterProvider)
+	})
+	t.Run("RejectsUnknownIDAttributeMode", func(t *testing.T) {
+		opts := OtelOptions{IDAttribute: "encrypted"}
+		assert.Error(t, opts.Validate())
+	})
+}
+
+func TestOtelCacheTelemetry(t *testing.T) {
+	// setupTracing replaces the global tracer provider for the duration of the
+	// test.
+	setupTracing := func(t *testing.T) *tracetest.SpanRecorder {
+		recorder := tracetest.NewSpanRecorder()
+		original := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		t.Cleanup(func() { otel.SetTracerProvider(original) })
+		return recorder
+	}
+	// idAttribute returns the id attribute of the span, if it has one.
+	idAttribute := func(span sdktrace.ReadOnlySpan) (string, bool) {
+		for _, attr := range span.Attributes() {
+			if string(attr.Key) == ttlCacheIDAttribute {
+				return attr.Value.AsString(), true
+			}
+		}
+		return "", false
+	}
+
+	t.Run("IDAttribute", func(t *testing.T) {
+		h := utility.NewSHA256Hash()
+		h.Add("secret-token")
+		hashed := h.Sum()
+
+		for mode, expected := range map[IDAttributeMode]string{
+			IDAttributePlain:   "secret-token",
+			IDAttributeHashed:  hashed,
+			IDAttributeOmitted: "",
+		} {
+			t.Run(string(mode), func(t *testing.T) {
+				recorder := setupTracing(t)
+				cache, err := WithOtelOptions[int](NewInMemory[int](), OtelOptions{Name: "tokens", IDAttribute: mode})
+				require.NoError(t, err)
+				defer cache.Close()
+
+				cache.Put(t.Context(), "secret-token", 1, time.Now().Add(time.Hour))
+				cache.Get(t.Context(), "secret-token", 0)
+				cache.Delete(t.Context(), "secret-token")
+
+				spans := recorder.Ended()
+				require.Len(t, spans, 3)
+				for _, span := range spans {
+					assert.Contains(t, span.Attributes(), attribute.String(ttlCacheNameAttribute, "tokens"))
+					id, ok := idAttribute(span)
+					if expected == "" {
+						assert.False(t, ok, "id should be omitted")
+						continue
+					}
+					require.True(t, ok)
+					assert.Equal(t, expected, id)
+				}
+			})
+		}
+	})
+	t.Run("Metrics", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		cache, err := WithOtelOptions[int](NewInMemory[int](), OtelOptions{
+			Name:          "tokens",
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+			ReportEntries: true,
+		})
+		require.NoError(t, err)
+
+		cache.Put(t.Context(), "a", 1, time.Now().Add(time.Hour))
+		cache.Put(t.Context(), "b", 2, time.Now().Add(time.Hour))
+		cache.Get(t.Context(), "a", 0)
+		cache.Get(t.Context(), "a", 0)
+		cache.Get(t.Context(), "missing", 0)
+		cache.Delete(t.Context(), "b")
+
+		collect := func() map[string]int64 {
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(t.Context(), &rm))
+			values := map[string]int64{}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					var points []metricdata.DataPoint[int64]
+					switch data := m.Data.(type) {
+					case metricdata.Gauge[int64]:
+						points = data.DataPoints
+					case metricdata.Sum[int64]:
+						points = data.DataPoints
+					}
+					for _, point := range points {
+						assert.Contains(t, point.Attributes.ToSlice(), attribute.String(ttlCacheNameAttribute, "tokens"))
+						values[m.Name] = point.Value
+					}
+				}
+			}
+			return values
+		}
+		assert.Equal(t, map[string]int64{
+			CacheHitsMetric:    2,
+			CacheMissesMetric:  1,
+			CachePutsMetric:    2,
+			CacheDeletesMetric: 1,
+			CacheEntriesMetric: 1,
+		}, collect())
+
+		t.Run("StopsReportingEntriesOnClose", func(t *testing.T) {
+			require.NoError(t, cache.Close())
+			assert.NotContains(t, collect(), CacheEntriesMetric)
+		})
+	})
+	t.Run("BulkOperations", func(t *testing.T) {
+		recorder := setupTracing(t)
+		reader := sdkmetric.NewManualReader()
+		cache, err := WithOtelBulk[int](NewInMemory[int](), OtelOptions{
+			Name:          "tokens",
+			IDAttribute:   IDAttributeOmitted,
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.PutMany(t.Context(), map[string]Entry[int]{
+			"a": {Value: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			"b": {Value: 2, ExpiresAt: time.Now().Add(time.Hour)},
+			"c": {Value: 3, ExpiresAt: time.Now().Add(time.Hour)},
+		})
+		cache.GetMany(t.Context(), []string{"a", "b", "missing"}, 0)
+		cache.DeleteMany(t.Context(), []string{"a"})
+		cache.Clear(t.Context())
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(t.Context(), &rm))
+		values := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+					for _, point := range sum.DataPoints {
+						values[m.Name] = point.Value
+					}
+				}
+			}
+		}
+		assert.Equal(t, map[string]int64{
+			CacheHitsMetric:    2,
+			CacheMissesMetric:  1,
+			CachePutsMetric:    3,
+			CacheDeletesMetric: 3,
+		}, values)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 4)
+		for _, span := range spans {
+			assert.Contains(t, span.Attributes(), attribute.String(ttlCacheNameAttribute, "tokens"))
+			for _, attr := range span.Attributes() {
+				assert.NotEqual(t, ttlCacheIDsAttribute, string(attr.Key), "ids should be omitted")
+			}
+		}
+		assert.Contains(t, spans[0].Attributes(), attribute.Int(ttlCacheCountAttribute, 3))
+	})
+	t.Run("BulkIDAttributes", func(t *testing.T) {
+		recorder := setupTracing(t)
+		cache, err := WithOtelBulk[int](NewInMemory[int](), OtelOptions{Name: "tokens", IDAttribute: IDAttributeHashed})
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.DeleteMany(t.Context(), []string{"secret-token"})
+		h := utility.NewSHA256Hash()
+		h.Add("secret-token")
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Contains(t, spans[0].Attributes(), attribute.StringSlice(ttlCacheIDsAttribute, []string{h.Sum()}))
+	})
+	t.Run("EntriesGaugeIsOptIn", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		cache, err := WithOtelOptions[int](NewInMemory[int](), OtelOptions{
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+		require.NoError(t, err)
+		assert.Nil(t, cache.registration)
+	})
+	t.Run("EntriesGaugeRequiresLen", func(t *testing.T) {
+		_, err := WithOtelOptions[int](WithLoading[int](NewInMemory[int]()), OtelOptions{
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewManualReader())),
+			ReportEntries: true,
+		})
+		assert.ErrorContains(t, err, "Len")
+	})
 }
@@ -0,0 +1,148 @@
+package ttlcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/pkg/errors"
+)
+
+// Entry is a value along with the time at which it expires.
+type Entry[T any] struct {
+	Value     T         `json:"value" yaml:"value"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// RefreshOptions configure a refreshing ttl cache.
+type RefreshOptions struct {
+	// RefreshAhead is the remaining lifetime under which a value is refreshed
+	// in the background when it is read. By default, values are not refreshed
+	// ahead of time.
+	RefreshAhead time.Duration
+	// StaleWhileRevalidate, if set, returns a value that is stale, rather than
+	// waiting for it to be reloaded, and reloads it in the background.
+	StaleWhileRevalidate bool
+	// StaleIfError, if set, returns a value that is stale when reloading it
+	// fails, rather than returning the error.
+	StaleIfError bool
+	// GracePeriod is how long after a value expires that it may still be
+	// returned as a stale value. By default, values are only stale while they
+	// have less than the requested minimum lifetime remaining.
+	GracePeriod time.Duration
+	// Clock is used to determine how long values have left before they
+	// expire. By default, it is the system clock.
+	Clock utility.Clock
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *RefreshOptions) Validate() error {
+	if o.Clock == nil {
+		o.Clock = utility.RealClock{}
+	}
+
+	if o.RefreshAhead < 0 {
+		return errors.New("refresh ahead must not be negative")
+	}
+	if o.GracePeriod < 0 {
+		return errors.New("grace period must not be negative")
+	}
+	return nil
+}
+
+// WithRefresh wraps a cache so that values loaded with GetOrLoad are refreshed
+// before they expire and, optionally, so that stale values can be returned
+// while they are reloaded. Entries are kept in the underlying cache until the
+// end of their grace period.
+func WithRefresh[T any](cache Cache[Entry[T]], opts RefreshOptions) (*RefreshingCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	return &RefreshingCache[T]{
+		cache: WithLoading(cache),
+		opts:  opts,
+	}, nil
+}
+
+type RefreshingCache[T any] struct {
+	cache *LoadingCache[Entry[T]]
+	opts  RefreshOptions
+}
+
+// GetOrLoad gets the value with id with at least the minimum lifetime
+// remaining. If there is no such value, it loads the value, adds it to the
+// cache and returns it. Concurrent loads for the same id, including background
+// refreshes, share a single call to the loader.
+//
+// A value with less than RefreshAhead remaining is returned and refreshed in
+// the background. A value that is stale is returned and refreshed in the
+// background if StaleWhileRevalidate is set, and is returned in place of the
+// loader's error if StaleIfError is set.
+func (c *RefreshingCache[T]) GetOrLoad(ctx context.Context, id string, minimumLifetime time.Duration, loader LoaderFunc[T]) (T, error) {
+	entryLoader := c.entryLoader(loader)
+
+	entry, ok := c.cache.Get(ctx, id, 0)
+	if ok {
+		remaining := entry.ExpiresAt.Sub(c.opts.Clock.Now())
+		if remaining >= minimumLifetime {
+			if remaining < c.opts.RefreshAhead {
+				c.refresh(ctx, id, entryLoader)
+			}
+			return entry.Value, nil
+		}
+		if c.opts.StaleWhileRevalidate {
+			c.refresh(ctx, id, entryLoader)
+			return entry.Value, nil
+		}
+	}
+
+	loaded, err := c.cache.load(ctx, id, entryLoader)
+	if err != nil {
+		if ok && c.opts.StaleIfError {
+			return entry.Value, nil
+		}
+		var zero T
+		return zero, err
+	}
+	return loaded.Value, nil
+}
+
+// refresh reloads the value with id in the background. The refresh is never
+// abandoned, so it is not canceled when other callers waiting on the same load
+// give up. Errors are ignored, since the value can be loaded again by the next
+// caller.
+func (c *RefreshingCache[T]) refresh(ctx context.Context, id string, loader LoaderFunc[Entry[T]]) {
+	c.cache.start(ctx, id, loader)
+}
+
+// entryLoader adapts a loader to load entries that are kept in the underlying
+// cache for their grace period.
+func (c *RefreshingCache[T]) entryLoader(loader LoaderFunc[T]) LoaderFunc[Entry[T]] {
+	return func(ctx context.Context) (Entry[T], time.Time, error) {
+		value, expiresAt, err := loader(ctx)
+		if err != nil {
+			return Entry[T]{}, time.Time{}, err
+		}
+		return Entry[T]{Value: value, ExpiresAt: expiresAt}, expiresAt.Add(c.opts.GracePeriod), nil
+	}
+}
+
+// Get gets the value with id with at least the minimum lifetime remaining.
+// Stale values are not returned.
+func (c *RefreshingCache[T]) Get(ctx context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	entry, ok := c.cache.Get(ctx, id, 0)
+	if !ok || entry.ExpiresAt.Sub(c.opts.Clock.Now()) < minimumLifetime {
+		var zero T
+		return zero, false
+	}
+	return entry.Value, true
+}
+
+func (c *RefreshingCache[T]) Put(ctx context.Context, id string, value T, expiresAt time.Time) {
+	c.cache.Put(ctx, id, Entry[T]{Value: value, ExpiresAt: expiresAt}, expiresAt.Add(c.opts.GracePeriod))
+}
+
+func (c *RefreshingCache[T]) Delete(ctx context.Context, id string) {
+	c.cache.Delete(ctx, id)
+}
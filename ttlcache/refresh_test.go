@@ -0,0 +1,162 @@
+package ttlcache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		var opts RefreshOptions
+		require.NoError(t, opts.Validate())
+		assert.NotNil(t, opts.Clock)
+	})
+	t.Run("RejectsNegativeDurations", func(t *testing.T) {
+		opts := RefreshOptions{RefreshAhead: -time.Second}
+		assert.Error(t, opts.Validate())
+		opts = RefreshOptions{GracePeriod: -time.Second}
+		assert.Error(t, opts.Validate())
+	})
+}
+
+func TestRefreshingCache(t *testing.T) {
+	testCache(t, func() Cache[*int] {
+		cache, err := WithRefresh(NewInMemory[Entry[*int]](), RefreshOptions{GracePeriod: time.Minute})
+		require.NoError(t, err)
+		return cache
+	})
+
+	newCache := func(t *testing.T, opts RefreshOptions) (*RefreshingCache[int], *utility.FakeClock) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		opts.Clock = clock
+		cache, err := WithRefresh(NewInMemoryWithOptions[Entry[int]](InMemoryOptions{Clock: clock}), opts)
+		require.NoError(t, err)
+		return cache, clock
+	}
+	// blockingLoader returns a loader that loads successive integers, each
+	// expiring an hour after it is loaded, once the returned channel receives.
+	blockingLoader := func(clock utility.Clock) (LoaderFunc[int], chan struct{}, *atomic.Int32) {
+		release := make(chan struct{})
+		var calls atomic.Int32
+		return func(ctx context.Context) (int, time.Time, error) {
+			n := calls.Add(1)
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return 0, time.Time{}, ctx.Err()
+			}
+			return int(n), clock.Now().Add(time.Hour), nil
+		}, release, &calls
+	}
+	failingLoader := func(context.Context) (int, time.Time, error) {
+		return 0, time.Time{}, errors.New("something went wrong")
+	}
+	// waitForValue waits until the cache has the given value.
+	waitForValue := func(t *testing.T, cache *RefreshingCache[int], id string, expected int) {
+		assert.Eventually(t, func() bool {
+			val, ok := cache.Get(t.Context(), id, 0)
+			return ok && val == expected
+		}, time.Second, time.Millisecond)
+	}
+
+	t.Run("LoadsMissingValue", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{})
+		loader, release, _ := blockingLoader(clock)
+		close(release)
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, val)
+
+		val, err = cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, val, "value should be cached")
+	})
+	t.Run("RefreshesAhead", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{RefreshAhead: 10 * time.Minute})
+		loader, release, calls := blockingLoader(clock)
+		cache.Put(t.Context(), "key", 0, clock.Now().Add(time.Hour))
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 0, val)
+		assert.Zero(t, calls.Load(), "value with enough lifetime remaining should not be refreshed")
+
+		clock.Advance(55 * time.Minute)
+		val, err = cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 0, val, "value should be returned while it is refreshed")
+
+		close(release)
+		waitForValue(t, cache, "key", 1)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+	t.Run("BlocksOnStaleValueByDefault", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{GracePeriod: time.Hour})
+		loader, release, _ := blockingLoader(clock)
+		close(release)
+		cache.Put(t.Context(), "key", 0, clock.Now().Add(time.Minute))
+
+		clock.Advance(2 * time.Minute)
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, val)
+	})
+	t.Run("StaleWhileRevalidate", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{StaleWhileRevalidate: true, GracePeriod: time.Hour})
+		loader, release, calls := blockingLoader(clock)
+		cache.Put(t.Context(), "key", 0, clock.Now().Add(time.Minute))
+
+		clock.Advance(2 * time.Minute)
+		_, ok := cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok, "Get should not return stale values")
+
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 0, val, "stale value should be returned while it is reloaded")
+		val, err = cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 0, val)
+
+		close(release)
+		waitForValue(t, cache, "key", 1)
+		assert.EqualValues(t, 1, calls.Load(), "background refreshes should be deduplicated")
+
+		t.Run("NotAfterGracePeriod", func(t *testing.T) {
+			clock.Advance(3 * time.Hour)
+			val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+			require.NoError(t, err)
+			assert.Equal(t, 2, val)
+		})
+	})
+	t.Run("StaleIfError", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{StaleIfError: true, GracePeriod: time.Hour})
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Minute))
+
+		clock.Advance(2 * time.Minute)
+		val, err := cache.GetOrLoad(t.Context(), "key", time.Minute, failingLoader)
+		require.NoError(t, err)
+		assert.Equal(t, 22, val)
+
+		t.Run("NotAfterGracePeriod", func(t *testing.T) {
+			clock.Advance(time.Hour)
+			_, err := cache.GetOrLoad(t.Context(), "key", time.Minute, failingLoader)
+			assert.EqualError(t, err, "something went wrong")
+		})
+	})
+	t.Run("ReturnsErrorWithoutStaleIfError", func(t *testing.T) {
+		cache, clock := newCache(t, RefreshOptions{GracePeriod: time.Hour})
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Minute))
+
+		clock.Advance(2 * time.Minute)
+		_, err := cache.GetOrLoad(t.Context(), "key", time.Minute, failingLoader)
+		assert.EqualError(t, err, "something went wrong")
+	})
+}
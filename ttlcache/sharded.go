@@ -0,0 +1,125 @@
+package ttlcache
+
+import (
+	"context"
+	"hash/maphash"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultShards = 32
+
+// ShardedOptions configure a sharded in-memory ttl cache.
+type ShardedOptions struct {
+	InMemoryOptions
+	// Shards is the number of shards, each with its own lock. By default, it
+	// is 32.
+	Shards int
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *ShardedOptions) Validate() error {
+	o.InMemoryOptions.Validate()
+	if o.Shards == 0 {
+		o.Shards = defaultShards
+	}
+
+	if o.Shards < 0 {
+		return errors.New("number of shards must not be negative")
+	}
+	return nil
+}
+
+// NewSharded creates a new thread-safe in-memory ttl cache that partitions its
+// entries by id across shards, so that operations on different shards do not
+// contend for the same lock.
+func NewSharded[T any](opts ShardedOptions) (*ShardedCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	// The janitor purges every shard, so it is started here rather than by
+	// each shard.
+	shardOpts := opts.InMemoryOptions
+	shardOpts.CleanupInterval = 0
+	c := &ShardedCache[T]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*InMemoryCache[T], opts.Shards),
+	}
+	for i := range c.shards {
+		c.shards[i] = NewInMemoryWithOptions[T](shardOpts)
+	}
+	if opts.CleanupInterval > 0 {
+		c.StartJanitor(context.Background(), opts.CleanupInterval)
+	}
+	return c, nil
+}
+
+type ShardedCache[T any] struct {
+	seed   maphash.Seed
+	shards []*InMemoryCache[T]
+}
+
+func (c *ShardedCache[T]) Get(ctx context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	return c.shard(id).Get(ctx, id, minimumLifetime)
+}
+
+func (c *ShardedCache[T]) Put(ctx context.Context, id string, value T, expiresAt time.Time) {
+	c.shard(id).Put(ctx, id, value, expiresAt)
+}
+
+func (c *ShardedCache[T]) Delete(ctx context.Context, id string) {
+	c.shard(id).Delete(ctx, id)
+}
+
+// OnEvict registers a function to call when an entry leaves the cache because
+// it was replaced, deleted, or purged after it expired.
+func (c *ShardedCache[T]) OnEvict(fn EvictFunc[T]) {
+	for _, shard := range c.shards {
+		shard.OnEvict(fn)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not been purged.
+func (c *ShardedCache[T]) Len() int {
+	var n int
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Purge removes every expired entry from the cache and returns the number of
+// entries removed. Shards are purged one at a time, so the other shards remain
+// available.
+func (c *ShardedCache[T]) Purge(ctx context.Context) int {
+	var purged int
+	for _, shard := range c.shards {
+		purged += shard.Purge(ctx)
+	}
+	return purged
+}
+
+// StartJanitor starts a goroutine that purges expired entries at the given
+// interval, as measured by the cache's clock, until the context is done or
+// the cache is closed. It does nothing if the interval is not positive.
+func (c *ShardedCache[T]) StartJanitor(ctx context.Context, interval time.Duration) {
+	c.shards[0].startJanitor(ctx, interval, c.Purge)
+}
+
+// Close stops any janitors and waits for them to exit. The cache can still be
+// used after it is closed.
+func (c *ShardedCache[T]) Close() error {
+	for _, shard := range c.shards {
+		_ = shard.Close()
+	}
+	return nil
+}
+
+// shard returns the shard that holds the entry with id.
+func (c *ShardedCache[T]) shard(id string) *InMemoryCache[T] {
+	return c.shards[maphash.String(c.seed, id)%uint64(len(c.shards))]
+}
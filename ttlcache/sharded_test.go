@@ -0,0 +1,165 @@
+package ttlcache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		var opts ShardedOptions
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, defaultShards, opts.Shards)
+		assert.NotNil(t, opts.Clock)
+	})
+	t.Run("RejectsNegativeShards", func(t *testing.T) {
+		opts := ShardedOptions{Shards: -1}
+		assert.Error(t, opts.Validate())
+	})
+}
+
+func TestShardedCache(t *testing.T) {
+	testCache(t, func() Cache[*int] {
+		cache, err := NewSharded[*int](ShardedOptions{})
+		require.NoError(t, err)
+		return cache
+	})
+
+	t.Run("OnEvict", func(t *testing.T) {
+		testOnEvict(t, func(clock utility.Clock) evictingCache {
+			cache, err := NewSharded[*int](ShardedOptions{InMemoryOptions: InMemoryOptions{Clock: clock}})
+			require.NoError(t, err)
+			return cache
+		})
+	})
+
+	newCache := func(t *testing.T, opts ShardedOptions) *ShardedCache[int] {
+		cache, err := NewSharded[int](opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, cache.Close()) })
+		return cache
+	}
+	numEntries := func(cache *ShardedCache[int]) int {
+		var n int
+		for _, shard := range cache.shards {
+			shard.mu.RLock()
+			n += len(shard.cache)
+			shard.mu.RUnlock()
+		}
+		return n
+	}
+
+	t.Run("DistributesEntries", func(t *testing.T) {
+		cache := newCache(t, ShardedOptions{Shards: 4})
+		for i := 0; i < 100; i++ {
+			cache.Put(t.Context(), fmt.Sprint(i), i, time.Now().Add(time.Hour))
+		}
+		assert.Equal(t, 100, numEntries(cache))
+		for _, shard := range cache.shards {
+			assert.NotEmpty(t, shard.cache)
+		}
+		for i := 0; i < 100; i++ {
+			val, ok := cache.Get(t.Context(), fmt.Sprint(i), time.Minute)
+			require.True(t, ok)
+			assert.Equal(t, i, val)
+		}
+	})
+	t.Run("UsesClock", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, ShardedOptions{InMemoryOptions: InMemoryOptions{Clock: clock}})
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		_, ok := cache.Get(t.Context(), "key", time.Hour)
+		require.True(t, ok)
+		clock.Advance(time.Minute)
+		_, ok = cache.Get(t.Context(), "key", time.Hour)
+		assert.False(t, ok)
+	})
+	t.Run("Purge", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, ShardedOptions{InMemoryOptions: InMemoryOptions{Clock: clock}, Shards: 4})
+		for i := 0; i < 20; i++ {
+			lifetime := time.Minute
+			if i%2 == 0 {
+				lifetime = time.Hour
+			}
+			cache.Put(t.Context(), fmt.Sprint(i), i, clock.Now().Add(lifetime))
+		}
+
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, 10, cache.Purge(t.Context()))
+		assert.Equal(t, 10, numEntries(cache))
+	})
+	t.Run("JanitorPurgesEveryShard", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := newCache(t, ShardedOptions{
+			InMemoryOptions: InMemoryOptions{Clock: clock, CleanupInterval: time.Minute},
+			Shards:          4,
+		})
+		for i := 0; i < 20; i++ {
+			cache.Put(t.Context(), fmt.Sprint(i), i, clock.Now().Add(30*time.Second))
+		}
+
+		require.NoError(t, clock.BlockUntil(t.Context(), 1))
+		assert.Equal(t, 1, clock.Waiters(), "there should be a single janitor for every shard")
+		clock.Advance(time.Minute)
+		assert.Eventually(t, func() bool { return numEntries(cache) == 0 }, time.Second, time.Millisecond)
+	})
+}
+
+// benchmarkCache runs parallel operations against the cache, reading the given
+// percentage of the time and writing otherwise.
+func benchmarkCache(b *testing.B, cache Cache[int], readPercent int) {
+	const numKeys = 1024
+	keys := make([]string, numKeys)
+	expiresAt := time.Now().Add(time.Hour)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		cache.Put(context.Background(), keys[i], i, expiresAt)
+	}
+
+	var goroutine atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		i := int(goroutine.Add(1)) * 7919
+		for pb.Next() {
+			i++
+			key := keys[i%numKeys]
+			if i%100 < readPercent {
+				cache.Get(ctx, key, time.Minute)
+			} else {
+				cache.Put(ctx, key, i, expiresAt)
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryCaches(b *testing.B) {
+	for _, workload := range []struct {
+		name        string
+		readPercent int
+	}{
+		{name: "Get", readPercent: 100},
+		{name: "Put", readPercent: 0},
+		{name: "Mixed", readPercent: 90},
+	} {
+		b.Run(workload.name, func(b *testing.B) {
+			b.Run("InMemory", func(b *testing.B) {
+				benchmarkCache(b, NewInMemory[int](), workload.readPercent)
+			})
+			b.Run("Sharded", func(b *testing.B) {
+				cache, err := NewSharded[int](ShardedOptions{})
+				require.NoError(b, err)
+				benchmarkCache(b, cache, workload.readPercent)
+			})
+		})
+	}
+}
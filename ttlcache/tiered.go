@@ -0,0 +1,99 @@
+package ttlcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriteMode determines which tiers of a tiered cache a value is written to.
+type WriteMode string
+
+const (
+	// WriteThrough writes values to every tier.
+	WriteThrough WriteMode = "write-through"
+	// WriteAround writes values only to the slowest tier and removes them
+	// from the faster tiers, which are filled when the values are read.
+	WriteAround WriteMode = "write-around"
+)
+
+// TieredOptions configure a tiered ttl cache.
+type TieredOptions struct {
+	// WriteMode determines which tiers values are written to. By default, it
+	// is WriteThrough.
+	WriteMode WriteMode
+}
+
+// Validate checks that the options are valid and sets defaults for
+// unspecified options.
+func (o *TieredOptions) Validate() error {
+	if o.WriteMode == "" {
+		o.WriteMode = WriteThrough
+	}
+
+	if o.WriteMode != WriteThrough && o.WriteMode != WriteAround {
+		return errors.Errorf("unknown write mode '%s'", o.WriteMode)
+	}
+	return nil
+}
+
+// NewTiered creates a ttl cache composed of tiers, ordered from fastest to
+// slowest. Reads check each tier in order and fill the faster tiers with a
+// value found in a slower one. Since the tiers hold entries, values copied
+// between tiers keep their original expiration time.
+func NewTiered[T any](opts TieredOptions, tiers ...Cache[Entry[T]]) (*TieredCache[T], error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("must specify at least one tier")
+	}
+	return &TieredCache[T]{opts: opts, tiers: tiers}, nil
+}
+
+type TieredCache[T any] struct {
+	opts  TieredOptions
+	tiers []Cache[Entry[T]]
+}
+
+func (c *TieredCache[T]) Get(ctx context.Context, id string, minimumLifetime time.Duration) (T, bool) {
+	for i, tier := range c.tiers {
+		entry, ok := tier.Get(ctx, id, minimumLifetime)
+		if !ok {
+			continue
+		}
+		for _, faster := range c.tiers[:i] {
+			faster.Put(ctx, id, entry, entry.ExpiresAt)
+		}
+		return entry.Value, true
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Put adds a value to the tiers according to the write mode. Slower tiers are
+// written first so that a concurrent read never fills a faster tier with the
+// value being replaced.
+func (c *TieredCache[T]) Put(ctx context.Context, id string, value T, expiresAt time.Time) {
+	entry := Entry[T]{Value: value, ExpiresAt: expiresAt}
+	slowest := len(c.tiers) - 1
+	c.tiers[slowest].Put(ctx, id, entry, expiresAt)
+	for i := slowest - 1; i >= 0; i-- {
+		switch c.opts.WriteMode {
+		case WriteThrough:
+			c.tiers[i].Put(ctx, id, entry, expiresAt)
+		case WriteAround:
+			c.tiers[i].Delete(ctx, id)
+		}
+	}
+}
+
+// Delete removes the value with id from every tier, starting with the slowest
+// so that a concurrent read cannot fill a faster tier with it again.
+func (c *TieredCache[T]) Delete(ctx context.Context, id string) {
+	for i := len(c.tiers) - 1; i >= 0; i-- {
+		c.tiers[i].Delete(ctx, id)
+	}
+}
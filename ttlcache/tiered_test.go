@@ -0,0 +1,125 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTieredOptions(t *testing.T) {
+	t.Run("SetsDefaults", func(t *testing.T) {
+		var opts TieredOptions
+		require.NoError(t, opts.Validate())
+		assert.Equal(t, WriteThrough, opts.WriteMode)
+	})
+	t.Run("RejectsUnknownWriteMode", func(t *testing.T) {
+		opts := TieredOptions{WriteMode: "write-back"}
+		assert.Error(t, opts.Validate())
+	})
+}
+
+func TestTieredCache(t *testing.T) {
+	for _, mode := range []WriteMode{WriteThrough, WriteAround} {
+		t.Run(string(mode), func(t *testing.T) {
+			testCache(t, func() Cache[*int] {
+				disk, err := NewDisk[Entry[*int]](DiskOptions{Dir: t.TempDir()})
+				require.NoError(t, err)
+				cache, err := NewTiered(TieredOptions{WriteMode: mode}, NewInMemory[Entry[*int]](), disk)
+				require.NoError(t, err)
+				return cache
+			})
+		})
+	}
+
+	t.Run("RequiresTiers", func(t *testing.T) {
+		_, err := NewTiered[int](TieredOptions{})
+		assert.Error(t, err)
+	})
+
+	newTiers := func(t *testing.T) (*utility.FakeClock, *InMemoryCache[Entry[int]], *DiskCache[Entry[int]]) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		memory := NewInMemoryWithOptions[Entry[int]](InMemoryOptions{Clock: clock})
+		disk, err := NewDisk[Entry[int]](DiskOptions{Dir: t.TempDir(), Clock: clock})
+		require.NoError(t, err)
+		return clock, memory, disk
+	}
+
+	t.Run("BackfillsFasterTiers", func(t *testing.T) {
+		clock, memory, disk := newTiers(t)
+		cache, err := NewTiered[int](TieredOptions{}, memory, disk)
+		require.NoError(t, err)
+		expiresAt := clock.Now().Add(time.Hour)
+		disk.Put(t.Context(), "key", Entry[int]{Value: 22, ExpiresAt: expiresAt}, expiresAt)
+
+		clock.Advance(10 * time.Minute)
+		val, ok := cache.Get(t.Context(), "key", time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, 22, val)
+
+		entry, ok := memory.Get(t.Context(), "key", 0)
+		require.True(t, ok, "faster tier should be filled")
+		assert.Equal(t, expiresAt, entry.ExpiresAt, "filled entry should keep its remaining lifetime")
+		_, ok = memory.Get(t.Context(), "key", 51*time.Minute)
+		assert.False(t, ok)
+	})
+	t.Run("ChecksSlowerTiersForMinimumLifetime", func(t *testing.T) {
+		clock, memory, disk := newTiers(t)
+		cache, err := NewTiered[int](TieredOptions{}, memory, disk)
+		require.NoError(t, err)
+		memory.Put(t.Context(), "key", Entry[int]{Value: 1, ExpiresAt: clock.Now().Add(time.Minute)}, clock.Now().Add(time.Minute))
+		disk.Put(t.Context(), "key", Entry[int]{Value: 2, ExpiresAt: clock.Now().Add(time.Hour)}, clock.Now().Add(time.Hour))
+
+		val, ok := cache.Get(t.Context(), "key", 10*time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, 2, val)
+		val, ok = cache.Get(t.Context(), "key", 10*time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, 2, val)
+	})
+	t.Run("WriteThrough", func(t *testing.T) {
+		clock, memory, disk := newTiers(t)
+		cache, err := NewTiered[int](TieredOptions{WriteMode: WriteThrough}, memory, disk)
+		require.NoError(t, err)
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		for _, tier := range []Cache[Entry[int]]{memory, disk} {
+			entry, ok := tier.Get(t.Context(), "key", 0)
+			require.True(t, ok)
+			assert.Equal(t, 22, entry.Value)
+		}
+	})
+	t.Run("WriteAround", func(t *testing.T) {
+		clock, memory, disk := newTiers(t)
+		cache, err := NewTiered[int](TieredOptions{WriteMode: WriteAround}, memory, disk)
+		require.NoError(t, err)
+		memory.Put(t.Context(), "key", Entry[int]{Value: 1, ExpiresAt: clock.Now().Add(time.Hour)}, clock.Now().Add(time.Hour))
+
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+		_, ok := memory.Get(t.Context(), "key", 0)
+		assert.False(t, ok, "stale value should be removed from the faster tier")
+		entry, ok := disk.Get(t.Context(), "key", 0)
+		require.True(t, ok)
+		assert.Equal(t, 22, entry.Value)
+
+		val, ok := cache.Get(t.Context(), "key", 0)
+		require.True(t, ok)
+		assert.Equal(t, 22, val)
+		_, ok = memory.Get(t.Context(), "key", 0)
+		assert.True(t, ok, "faster tier should be filled on read")
+	})
+	t.Run("DeletesFromEveryTier", func(t *testing.T) {
+		clock, memory, disk := newTiers(t)
+		cache, err := NewTiered[int](TieredOptions{}, memory, disk)
+		require.NoError(t, err)
+		cache.Put(t.Context(), "key", 22, clock.Now().Add(time.Hour))
+
+		cache.Delete(t.Context(), "key")
+		for _, tier := range []Cache[Entry[int]]{memory, disk} {
+			_, ok := tier.Get(t.Context(), "key", 0)
+			assert.False(t, ok)
+		}
+	})
+}
@@ -2,6 +2,8 @@ package ttlcache
 
 import (
 	"context"
+	"iter"
+	"slices"
 	"time"
 	"weak"
 )
@@ -12,9 +14,23 @@ import (
 // that may not be needed for long periods of time, as it allows the memory to be reclaimed
 // when the object is no longer needed.
 func NewWeakInMemory[T any]() *WeakInMemory[T] {
-	return &WeakInMemory[T]{
-		cache: NewInMemory[weak.Pointer[T]](),
+	return NewWeakInMemoryWithOptions[T](InMemoryOptions{})
+}
+
+// NewWeakInMemoryWithOptions creates a new thread-safe in-memory ttl cache
+// that uses weak references with the given options.
+func NewWeakInMemoryWithOptions[T any](opts InMemoryOptions) *WeakInMemory[T] {
+	// The janitor must also purge garbage collected values, so it is started
+	// here rather than by the underlying cache.
+	cleanupInterval := opts.CleanupInterval
+	opts.CleanupInterval = 0
+	w := &WeakInMemory[T]{
+		cache: NewInMemoryWithOptions[weak.Pointer[T]](opts),
+	}
+	if cleanupInterval > 0 {
+		w.StartJanitor(context.Background(), cleanupInterval)
 	}
+	return w
 }
 
 type WeakInMemory[T any] struct {
@@ -29,7 +45,7 @@ func (w *WeakInMemory[T]) Get(ctx context.Context, id string, minimumLifetime ti
 	val := weakVal.Value()
 	if val == nil {
 		// Clean up the cache if the value is nil.
-		w.Delete(ctx, id)
+		w.removeCollected(id, weakVal)
 
 		return nil, false
 	}
@@ -44,3 +60,126 @@ func (w *WeakInMemory[T]) Put(ctx context.Context, id string, value *T, expiresA
 func (c *WeakInMemory[T]) Delete(ctx context.Context, id string) {
 	c.cache.Delete(ctx, id)
 }
+
+// GetMany gets the values with the given ids that have at least the minimum
+// lifetime remaining and have not been garbage collected.
+func (w *WeakInMemory[T]) GetMany(ctx context.Context, ids []string, minimumLifetime time.Duration) map[string]*T {
+	weakVals := w.cache.GetMany(ctx, ids, minimumLifetime)
+	values := make(map[string]*T, len(weakVals))
+	for id, weakVal := range weakVals {
+		val := weakVal.Value()
+		if val == nil {
+			w.removeCollected(id, weakVal)
+			continue
+		}
+		values[id] = val
+	}
+	return values
+}
+
+// PutMany adds the entries to the cache, keyed by id.
+func (w *WeakInMemory[T]) PutMany(ctx context.Context, entries map[string]Entry[*T]) {
+	weakEntries := make(map[string]Entry[weak.Pointer[T]], len(entries))
+	for id, entry := range entries {
+		weakEntries[id] = Entry[weak.Pointer[T]]{Value: weak.Make(entry.Value), ExpiresAt: entry.ExpiresAt}
+	}
+	w.cache.PutMany(ctx, weakEntries)
+}
+
+// DeleteMany removes the values with the given ids from the cache.
+func (w *WeakInMemory[T]) DeleteMany(ctx context.Context, ids []string) {
+	w.cache.DeleteMany(ctx, ids)
+}
+
+// Clear removes every entry from the cache. The entries are reported to the
+// eviction listeners as deleted.
+func (w *WeakInMemory[T]) Clear(ctx context.Context) {
+	w.cache.Clear(ctx)
+}
+
+// Keys returns the ids of the entries that have not expired or been garbage
+// collected, in sorted order.
+func (w *WeakInMemory[T]) Keys(ctx context.Context) []string {
+	var ids []string
+	for id := range w.All(ctx) {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// All returns an iterator over the entries that have not expired or been
+// garbage collected, keyed by id. The entries are read when iteration starts,
+// so the cache may be used while iterating.
+func (w *WeakInMemory[T]) All(ctx context.Context) iter.Seq2[string, LiveEntry[*T]] {
+	return func(yield func(string, LiveEntry[*T]) bool) {
+		for id, entry := range w.cache.All(ctx) {
+			val := entry.Value.Value()
+			if val == nil {
+				continue
+			}
+			if !yield(id, LiveEntry[*T]{Value: val, TTL: entry.TTL}) {
+				return
+			}
+		}
+	}
+}
+
+// removeCollected removes the entry with id if it still holds the given
+// pointer, whose value has been garbage collected.
+func (w *WeakInMemory[T]) removeCollected(id string, ptr weak.Pointer[T]) {
+	w.cache.mu.Lock()
+	defer w.cache.unlock()
+
+	if cachedToken, ok := w.cache.cache[id]; ok && cachedToken.value == ptr {
+		w.cache.evict(id, ptr, EvictionCollected)
+		delete(w.cache.cache, id)
+	}
+}
+
+// OnEvict registers a function to call when an entry leaves the cache because
+// it was replaced, deleted, purged after it expired, or found to have been
+// garbage collected. The value is nil if it has been garbage collected.
+func (w *WeakInMemory[T]) OnEvict(fn EvictFunc[*T]) {
+	w.cache.OnEvict(func(id string, ptr weak.Pointer[T], reason EvictionReason) {
+		fn(id, ptr.Value(), reason)
+	})
+}
+
+// Len returns the number of entries in the cache, including expired and
+// garbage collected entries that have not been purged.
+func (w *WeakInMemory[T]) Len() int {
+	return w.cache.Len()
+}
+
+// Purge removes every expired entry, and every entry whose value has been
+// garbage collected, from the cache and returns the number of entries
+// removed.
+func (w *WeakInMemory[T]) Purge(ctx context.Context) int {
+	purged := w.cache.Purge(ctx)
+
+	w.cache.mu.Lock()
+	defer w.cache.unlock()
+	for id, cachedToken := range w.cache.cache {
+		if cachedToken.value.Value() == nil {
+			w.cache.evict(id, cachedToken.value, EvictionCollected)
+			delete(w.cache.cache, id)
+			purged++
+		}
+	}
+	return purged
+}
+
+// StartJanitor starts a goroutine that purges expired and garbage collected
+// entries at the given interval, as measured by the cache's clock, until the
+// context is done or the cache is closed. It does nothing if the interval is
+// not positive.
+func (w *WeakInMemory[T]) StartJanitor(ctx context.Context, interval time.Duration) {
+	w.cache.startJanitor(ctx, interval, w.Purge)
+}
+
+// Close stops any janitors and waits for them to exit. The cache can still be
+// used after it is closed.
+func (w *WeakInMemory[T]) Close() error {
+	return w.cache.Close()
+}
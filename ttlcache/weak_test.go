@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/evergreen-ci/utility"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,6 +15,77 @@ func TestTTLWeakInMemoryCache(t *testing.T) {
 		return NewWeakInMemory[int]()
 	})
 
+	t.Run("OnEvict", func(t *testing.T) {
+		testOnEvict(t, func(clock utility.Clock) evictingCache {
+			return NewWeakInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+		})
+
+		t.Run("Collected", func(t *testing.T) {
+			cache := NewWeakInMemory[string]()
+			recorder := &evictionRecorder{}
+			cache.OnEvict(func(id string, value *string, reason EvictionReason) {
+				assert.Nil(t, value)
+				recorder.record(id, nil, reason)
+			})
+			newValue := func() *string {
+				s := "collected"
+				return &s
+			}
+			cache.Put(t.Context(), "purged", newValue(), time.Now().Add(time.Hour))
+			cache.Put(t.Context(), "read", newValue(), time.Now().Add(time.Hour))
+
+			runtime.GC()
+			_, found := cache.Get(t.Context(), "read", 0)
+			require.False(t, found)
+			assert.Equal(t, []recordedEviction{{id: "read", reason: EvictionCollected}}, recorder.get())
+
+			assert.Equal(t, 1, cache.Purge(t.Context()))
+			assert.Equal(t, []recordedEviction{
+				{id: "read", reason: EvictionCollected},
+				{id: "purged", reason: EvictionCollected},
+			}, recorder.get())
+		})
+	})
+
+	t.Run("BulkCache", func(t *testing.T) {
+		testBulkCache(t, func(clock utility.Clock) BulkCache[*int] {
+			return NewWeakInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+		})
+	})
+	t.Run("BulkCacheSkipsCollectedValues", func(t *testing.T) {
+		cache := NewWeakInMemory[string]()
+		live := "live"
+		cache.PutMany(t.Context(), map[string]Entry[*string]{
+			"live":      {Value: &live, ExpiresAt: time.Now().Add(time.Hour)},
+			"collected": {Value: new(string), ExpiresAt: time.Now().Add(time.Hour)},
+		})
+
+		runtime.GC()
+		assert.Equal(t, []string{"live"}, cache.Keys(t.Context()))
+		for id := range cache.All(t.Context()) {
+			assert.Equal(t, "live", id)
+		}
+		assert.Equal(t, map[string]*string{"live": &live}, cache.GetMany(t.Context(), []string{"live", "collected"}, 0))
+		assert.Equal(t, 1, cache.Len(), "GetMany should remove collected values")
+		runtime.KeepAlive(&live)
+	})
+
+	t.Run("UsesClock", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := NewWeakInMemoryWithOptions[int](InMemoryOptions{Clock: clock})
+		val := 22
+		cache.Put(t.Context(), "key", &val, clock.Now().Add(time.Hour))
+
+		cachedVal, ok := cache.Get(t.Context(), "key", time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, val, *cachedVal)
+
+		clock.Advance(time.Hour + time.Second)
+		_, ok = cache.Get(t.Context(), "key", 0)
+		assert.False(t, ok)
+		runtime.KeepAlive(&val)
+	})
+
 	t.Run("ReleasesMemory", func(t *testing.T) {
 		getItem := func() *string {
 			i := "itemValue"
@@ -43,4 +115,27 @@ func TestTTLWeakInMemoryCache(t *testing.T) {
 		assert.False(t, found)
 		assert.Nil(t, cachedItem)
 	})
+
+	t.Run("PurgesCollectedValues", func(t *testing.T) {
+		clock := utility.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+		cache := NewWeakInMemoryWithOptions[string](InMemoryOptions{Clock: clock})
+		defer cache.Close()
+
+		kept := "kept"
+		expiring := "expiring"
+		cache.Put(t.Context(), "kept", &kept, clock.Now().Add(time.Hour))
+		cache.Put(t.Context(), "expiring", &expiring, clock.Now().Add(time.Minute))
+		cache.Put(t.Context(), "collected", func() *string {
+			s := "collected"
+			return &s
+		}(), clock.Now().Add(time.Hour))
+
+		runtime.GC()
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, 2, cache.Purge(t.Context()))
+		_, found := cache.Get(t.Context(), "kept", 0)
+		assert.True(t, found)
+		runtime.KeepAlive(&kept)
+		runtime.KeepAlive(&expiring)
+	})
 }
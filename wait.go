@@ -0,0 +1,155 @@
+package utility
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWaitAttemptTimeout = 5 * time.Second
+	defaultWaitMaxDelay       = 5 * time.Second
+	// maxWaitFailures is the number of most recent failures reported when
+	// waiting does not succeed.
+	maxWaitFailures = 5
+)
+
+// WaitOptions configure waiting for an endpoint to become ready.
+type WaitOptions struct {
+	// RetryOptions configures the backoff between attempts. By default,
+	// attempts are unlimited, so waiting is bounded only by the context or
+	// MaxElapsedTime, the maximum delay between attempts is 5 seconds, and
+	// each attempt times out after 5 seconds.
+	RetryOptions RetryOptions
+	// ExpectedStatuses are the HTTP status codes that indicate the endpoint is
+	// ready. By default, any 2xx status is accepted. This is ignored when
+	// waiting for TCP connections.
+	ExpectedStatuses []int
+	// BodyPredicate, if set, must return true for the response body for the
+	// endpoint to be considered ready. This is ignored when waiting for TCP
+	// connections.
+	BodyPredicate func(body []byte) bool
+	// Client is the HTTP client used to check the endpoint. By default, a
+	// client from the pool is used.
+	Client *http.Client
+}
+
+// Validate sets defaults for unspecified or invalid options.
+func (o *WaitOptions) Validate() {
+	if o.RetryOptions.MaxAttempts <= 0 {
+		o.RetryOptions.MaxAttempts = math.MaxInt32
+	}
+	if o.RetryOptions.MaxDelay <= 0 {
+		o.RetryOptions.MaxDelay = defaultWaitMaxDelay
+	}
+	if o.RetryOptions.AttemptTimeout <= 0 {
+		o.RetryOptions.AttemptTimeout = defaultWaitAttemptTimeout
+	}
+	o.RetryOptions.Validate()
+}
+
+// WaitForHTTP polls the URL with GET requests until it responds with an
+// expected status and, if a body predicate is given, a matching body. If the
+// endpoint does not become ready before the context is done or the attempts
+// are exhausted, the returned error lists the most recent failures.
+func WaitForHTTP(ctx context.Context, url string, opts WaitOptions) error {
+	opts.Validate()
+
+	client := opts.Client
+	if client == nil {
+		client = GetHTTPClient()
+		defer PutHTTPClient(client)
+	}
+
+	return waitFor(ctx, fmt.Sprintf("HTTP endpoint '%s'", url), opts, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return Permanent(errors.Wrap(err, "creating request"))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "sending request")
+		}
+		defer resp.Body.Close()
+
+		if !isExpectedStatus(resp.StatusCode, opts.ExpectedStatuses) {
+			return errors.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		if opts.BodyPredicate == nil {
+			return nil
+		}
+
+		body, err := io.ReadAll(NewResponseReader(resp))
+		if err != nil {
+			return errors.Wrap(err, "reading response body")
+		}
+		if !opts.BodyPredicate(body) {
+			return errors.New("response body did not match predicate")
+		}
+
+		return nil
+	})
+}
+
+// WaitForTCP attempts to connect to the TCP address until it accepts a
+// connection. If the address does not accept a connection before the context
+// is done or the attempts are exhausted, the returned error lists the most
+// recent failures.
+func WaitForTCP(ctx context.Context, addr string, opts WaitOptions) error {
+	opts.Validate()
+
+	var dialer net.Dialer
+	return waitFor(ctx, fmt.Sprintf("TCP address '%s'", addr), opts, func(ctx context.Context) error {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return errors.Wrap(err, "connecting")
+		}
+		return errors.Wrap(conn.Close(), "closing connection")
+	})
+}
+
+// waitFor retries check until it succeeds, recording the most recent
+// failures to describe why waiting failed.
+func waitFor(ctx context.Context, name string, opts WaitOptions, check func(context.Context) error) error {
+	var failures []string
+	_, err := RetryValue(ctx, func(ctx context.Context, attempt int) (struct{}, bool, error) {
+		err := check(ctx)
+		if err == nil {
+			return struct{}{}, false, nil
+		}
+
+		failures = append(failures, fmt.Sprintf("attempt %d: %s", attempt, err))
+		if len(failures) > maxWaitFailures {
+			failures = failures[1:]
+		}
+		return struct{}{}, true, err
+	}, opts.RetryOptions)
+	if err == nil {
+		return nil
+	}
+
+	if len(failures) == 0 {
+		return errors.Wrapf(err, "waiting for %s", name)
+	}
+	return errors.Wrapf(err, "waiting for %s (most recent failures: %s)", name, strings.Join(failures, "; "))
+}
+
+func isExpectedStatus(status int, expected []int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range expected {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package utility
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForHTTP(t *testing.T) {
+	retryOpts := RetryOptions{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	t.Run("SucceedsOnceEndpointIsReady", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		require.NoError(t, WaitForHTTP(t.Context(), srv.URL, WaitOptions{RetryOptions: retryOpts}))
+		assert.EqualValues(t, 3, calls.Load())
+	})
+	t.Run("AcceptsExpectedStatuses", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		assert.NoError(t, WaitForHTTP(t.Context(), srv.URL, WaitOptions{
+			RetryOptions:     retryOpts,
+			ExpectedStatuses: []int{http.StatusUnauthorized},
+		}))
+	})
+	t.Run("WaitsForBodyPredicate", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 2 {
+				_, _ = w.Write([]byte("starting"))
+				return
+			}
+			_, _ = w.Write([]byte("ready"))
+		}))
+		defer srv.Close()
+
+		require.NoError(t, WaitForHTTP(t.Context(), srv.URL, WaitOptions{
+			RetryOptions:  retryOpts,
+			BodyPredicate: func(body []byte) bool { return string(body) == "ready" },
+		}))
+		assert.EqualValues(t, 2, calls.Load())
+	})
+	t.Run("TimesOutSlowAttempts", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 2 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		start := time.Now()
+		opts := retryOpts
+		opts.AttemptTimeout = 50 * time.Millisecond
+		require.NoError(t, WaitForHTTP(t.Context(), srv.URL, WaitOptions{RetryOptions: opts}))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+	t.Run("ReportsRecentFailures", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		err := WaitForHTTP(t.Context(), srv.URL, WaitOptions{RetryOptions: RetryOptions{
+			MaxAttempts: maxWaitFailures + 2,
+			MinDelay:    time.Millisecond,
+			MaxDelay:    time.Millisecond,
+		}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), srv.URL)
+		assert.Contains(t, err.Error(), "unexpected status 503")
+		assert.Contains(t, err.Error(), "attempt 7")
+		assert.NotContains(t, err.Error(), "attempt 2:", "only the most recent failures should be reported")
+	})
+	t.Run("DoesNotRetryInvalidURL", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		err := WaitForHTTP(ctx, "http://[::1", WaitOptions{RetryOptions: retryOpts})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "creating request")
+		assert.Contains(t, err.Error(), "attempt 1:")
+		assert.NotContains(t, err.Error(), "attempt 2:")
+		assert.NoError(t, ctx.Err(), "an invalid URL should fail without waiting for the context")
+	})
+	t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		err := WaitForHTTP(ctx, srv.URL, WaitOptions{RetryOptions: retryOpts})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected status 503")
+	})
+}
+
+func TestWaitForTCP(t *testing.T) {
+	retryOpts := RetryOptions{MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	t.Run("SucceedsWhenListening", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		assert.NoError(t, WaitForTCP(t.Context(), listener.Addr().String(), WaitOptions{RetryOptions: retryOpts}))
+	})
+	t.Run("WaitsForListener", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		listening := make(chan net.Listener, 1)
+		go func() {
+			defer close(listening)
+			time.Sleep(100 * time.Millisecond)
+			if listener, err := net.Listen("tcp", addr); err == nil {
+				listening <- listener
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, WaitForTCP(ctx, addr, WaitOptions{RetryOptions: retryOpts}))
+		if listener, ok := <-listening; ok {
+			assert.NoError(t, listener.Close())
+		}
+	})
+	t.Run("ReportsFailures", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		err = WaitForTCP(t.Context(), addr, WaitOptions{RetryOptions: RetryOptions{MaxAttempts: 2, MinDelay: time.Millisecond}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), addr)
+		assert.Contains(t, err.Error(), "attempt 2")
+	})
+}
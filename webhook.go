@@ -0,0 +1,389 @@
+package utility
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// WebhookSignatureHeader is the default header containing the HMAC-SHA256
+	// signature of a webhook payload.
+	WebhookSignatureHeader = "X-Webhook-Signature-256"
+	// WebhookIDHeader is the header containing the unique ID of a webhook
+	// delivery, which receivers can use to deduplicate redelivered payloads.
+	WebhookIDHeader = "X-Webhook-Id"
+
+	webhookSignaturePrefix  = "sha256="
+	webhookPendingDir       = "pending"
+	webhookDeadLetterDir    = "dead-letter"
+	webhookDeliveryFileExt  = ".json"
+	webhookDefaultMediaType = "application/json"
+	webhookDeliveryIDSize   = 16
+)
+
+// SignWebhookPayload returns the signature of the payload as it is sent in the
+// signature header, which is the hex-encoded HMAC-SHA256 of the payload
+// prefixed with "sha256=".
+func SignWebhookPayload(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(payload)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns whether the signature matches the payload
+// signed with the secret. The comparison is done in constant time.
+func VerifyWebhookSignature(secret, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, payload)), []byte(signature))
+}
+
+// WebhookDelivery is a single payload to be delivered to a webhook URL.
+type WebhookDelivery struct {
+	ID        string      `json:"id"`
+	URL       string      `json:"url"`
+	Payload   []byte      `json:"payload"`
+	Header    http.Header `json:"header,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	// Attempts is the number of delivery attempts that have failed so far.
+	Attempts int `json:"attempts"`
+	// LastError is the error from the most recent failed attempt.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// WebhookDispatcherOptions configure a WebhookDispatcher.
+type WebhookDispatcherOptions struct {
+	// Directory is where pending and dead-lettered deliveries are persisted.
+	// It is created if it does not exist.
+	Directory string
+	// Secret is the key used to sign payloads.
+	Secret []byte
+	// SignatureHeader is the header that contains the payload signature. By
+	// default, it is WebhookSignatureHeader.
+	SignatureHeader string
+	// RetryOptions configures the backoff between attempts. MaxAttempts is
+	// the total number of attempts for a delivery, including attempts made
+	// before a restart.
+	RetryOptions RetryOptions
+	// Client is the HTTP client used to deliver payloads. By default, a
+	// client from the pool is used for each attempt.
+	Client *http.Client
+}
+
+// Validate checks that the required options are set and sets defaults for
+// unspecified options.
+func (o *WebhookDispatcherOptions) Validate() error {
+	if o.Directory == "" {
+		return errors.New("must specify a directory for persisting deliveries")
+	}
+	if len(o.Secret) == 0 {
+		return errors.New("must specify a secret for signing payloads")
+	}
+	if o.SignatureHeader == "" {
+		o.SignatureHeader = WebhookSignatureHeader
+	}
+	o.RetryOptions.Validate()
+	return nil
+}
+
+// WebhookDispatcher delivers signed payloads to webhook URLs. Deliveries are
+// persisted to disk until they succeed so that they survive restarts, and
+// deliveries that exhaust their attempts or are permanently rejected are kept
+// in a dead-letter list.
+type WebhookDispatcher struct {
+	opts     WebhookDispatcherOptions
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// NewWebhookDispatcher returns a WebhookDispatcher that persists deliveries
+// in the configured directory. Pending deliveries from a previous process are
+// not sent until DeliverPending is called.
+func NewWebhookDispatcher(opts WebhookDispatcherOptions) (*WebhookDispatcher, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	for _, dir := range []string{webhookPendingDir, webhookDeadLetterDir} {
+		if err := os.MkdirAll(filepath.Join(opts.Directory, dir), 0755); err != nil {
+			return nil, errors.Wrapf(err, "creating directory '%s'", dir)
+		}
+	}
+
+	return &WebhookDispatcher{
+		opts:     opts,
+		inFlight: map[string]bool{},
+	}, nil
+}
+
+// Enqueue persists a new delivery of the payload to the URL without sending
+// it. It is sent by the next call to DeliverPending.
+func (d *WebhookDispatcher) Enqueue(url string, payload []byte, header http.Header) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{
+		ID:        MakeRandomString(webhookDeliveryIDSize),
+		URL:       url,
+		Payload:   payload,
+		Header:    header,
+		CreatedAt: time.Now(),
+	}
+	if err := d.writeDelivery(webhookPendingDir, delivery); err != nil {
+		return nil, errors.Wrap(err, "persisting delivery")
+	}
+	return delivery, nil
+}
+
+// Send persists a new delivery of the payload to the URL and sends it,
+// retrying until it succeeds, it runs out of attempts or the context is
+// done. If the context is done first, the delivery remains pending.
+func (d *WebhookDispatcher) Send(ctx context.Context, url string, payload []byte, header http.Header) error {
+	delivery, err := d.Enqueue(url, payload, header)
+	if err != nil {
+		return err
+	}
+	return d.deliver(ctx, delivery)
+}
+
+// DeliverPending sends every pending delivery, including those persisted by
+// a previous process. It returns an error describing every delivery that did
+// not succeed.
+func (d *WebhookDispatcher) DeliverPending(ctx context.Context) error {
+	pending, err := d.Pending()
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	for i := range pending {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "delivering pending webhooks")
+		}
+		if err := d.deliver(ctx, &pending[i]); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("%d of %d pending deliveries failed: %s", len(failures), len(pending), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// Pending returns the deliveries that have not been sent yet, oldest first.
+func (d *WebhookDispatcher) Pending() ([]WebhookDelivery, error) {
+	return d.listDeliveries(webhookPendingDir)
+}
+
+// DeadLetters returns the deliveries that exhausted their attempts or were
+// permanently rejected, oldest first.
+func (d *WebhookDispatcher) DeadLetters() ([]WebhookDelivery, error) {
+	return d.listDeliveries(webhookDeadLetterDir)
+}
+
+// Redeliver moves a dead-lettered delivery back to pending with its attempts
+// reset and sends it again.
+func (d *WebhookDispatcher) Redeliver(ctx context.Context, id string) error {
+	if err := validateDeliveryID(id); err != nil {
+		return err
+	}
+	delivery := &WebhookDelivery{}
+	if err := ReadJSONFile(d.deliveryPath(webhookDeadLetterDir, id), delivery); err != nil {
+		return errors.Wrapf(err, "reading dead-lettered delivery '%s'", id)
+	}
+
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	if err := d.writeDelivery(webhookPendingDir, delivery); err != nil {
+		return errors.Wrapf(err, "persisting delivery '%s'", id)
+	}
+	if err := os.Remove(d.deliveryPath(webhookDeadLetterDir, id)); err != nil {
+		return errors.Wrapf(err, "removing dead-lettered delivery '%s'", id)
+	}
+
+	return d.deliver(ctx, delivery)
+}
+
+// RemoveDeadLetter discards a dead-lettered delivery.
+func (d *WebhookDispatcher) RemoveDeadLetter(id string) error {
+	if err := validateDeliveryID(id); err != nil {
+		return err
+	}
+	return errors.Wrapf(os.Remove(d.deliveryPath(webhookDeadLetterDir, id)), "removing dead-lettered delivery '%s'", id)
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *WebhookDelivery) error {
+	if !d.startDelivery(delivery.ID) {
+		return errors.Errorf("delivery '%s' is already in progress", delivery.ID)
+	}
+	defer d.finishDelivery(delivery.ID)
+
+	// The delivery may have been read before another call finished it, in
+	// which case it must not be sent again.
+	if _, err := os.Stat(d.deliveryPath(webhookPendingDir, delivery.ID)); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "checking that delivery '%s' is still pending", delivery.ID)
+	}
+
+	opts := d.opts.RetryOptions
+	opts.MaxAttempts -= delivery.Attempts
+	if opts.MaxAttempts <= 0 {
+		return d.deadLetter(delivery)
+	}
+
+	err := Retry(ctx, func() (bool, error) {
+		err := d.post(ctx, delivery)
+		if err == nil {
+			return false, nil
+		}
+
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		if writeErr := d.writeDelivery(webhookPendingDir, delivery); writeErr != nil {
+			return false, errors.Wrap(writeErr, "persisting delivery attempt")
+		}
+
+		return true, err
+	}, opts)
+	if err == nil {
+		return errors.Wrapf(os.Remove(d.deliveryPath(webhookPendingDir, delivery.ID)), "removing delivered delivery '%s'", delivery.ID)
+	}
+	if ctx.Err() != nil {
+		// The delivery stays pending so it can be resumed later.
+		return errors.Wrapf(err, "delivering '%s'", delivery.ID)
+	}
+
+	if dlErr := d.deadLetter(delivery); dlErr != nil {
+		return errors.Wrapf(dlErr, "dead-lettering delivery '%s' after error: %s", delivery.ID, err)
+	}
+	return errors.Wrapf(err, "delivering '%s'", delivery.ID)
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return Permanent(errors.Wrap(err, "creating request"))
+	}
+	for key, values := range delivery.Header {
+		for _, val := range values {
+			req.Header.Add(key, val)
+		}
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", webhookDefaultMediaType)
+	}
+	req.Header.Set(WebhookIDHeader, delivery.ID)
+	req.Header.Set(d.opts.SignatureHeader, SignWebhookPayload(d.opts.Secret, delivery.Payload))
+
+	client := d.opts.Client
+	if client == nil {
+		client = GetHTTPClient()
+		defer PutHTTPClient(client)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, NewResponseReader(resp))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = errors.Errorf("webhook returned status %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+func (d *WebhookDispatcher) deadLetter(delivery *WebhookDelivery) error {
+	if err := d.writeDelivery(webhookDeadLetterDir, delivery); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Remove(d.deliveryPath(webhookPendingDir, delivery.ID)), "removing pending delivery")
+}
+
+func (d *WebhookDispatcher) startDelivery(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.inFlight[id] {
+		return false
+	}
+	d.inFlight[id] = true
+	return true
+}
+
+func (d *WebhookDispatcher) finishDelivery(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, id)
+}
+
+// validateDeliveryID checks that a caller-supplied id has the format of the
+// ids generated for deliveries, so that it cannot refer to a file outside of
+// the delivery directories.
+func validateDeliveryID(id string) error {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 2*webhookDeliveryIDSize {
+		return errors.Errorf("invalid delivery id '%s'", id)
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) deliveryPath(dir, id string) string {
+	return filepath.Join(d.opts.Directory, dir, id+webhookDeliveryFileExt)
+}
+
+// writeDelivery persists the delivery by writing it to a temporary file and
+// renaming it into place, so a crash never leaves a partially-written file.
+func (d *WebhookDispatcher) writeDelivery(dir string, delivery *WebhookDelivery) error {
+	path := d.deliveryPath(dir, delivery.ID)
+	tmpPath := path + ".tmp"
+	if err := WriteJSONFile(tmpPath, delivery); err != nil {
+		return errors.Wrapf(err, "writing delivery '%s'", delivery.ID)
+	}
+	return errors.Wrapf(os.Rename(tmpPath, path), "moving delivery '%s' into place", delivery.ID)
+}
+
+func (d *WebhookDispatcher) listDeliveries(dir string) ([]WebhookDelivery, error) {
+	entries, err := os.ReadDir(filepath.Join(d.opts.Directory, dir))
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing '%s' deliveries", dir)
+	}
+
+	deliveries := []WebhookDelivery{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != webhookDeliveryFileExt {
+			continue
+		}
+
+		delivery := WebhookDelivery{}
+		if err := ReadJSONFile(filepath.Join(d.opts.Directory, dir, entry.Name()), &delivery); err != nil {
+			return nil, errors.Wrapf(err, "reading delivery file '%s'", entry.Name())
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+
+	return deliveries, nil
+}
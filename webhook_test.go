@@ -0,0 +1,225 @@
+package utility
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	secret := []byte("secret")
+	payload := []byte(`{"event": "created"}`)
+
+	signature := SignWebhookPayload(secret, payload)
+	assert.Contains(t, signature, webhookSignaturePrefix)
+	assert.True(t, VerifyWebhookSignature(secret, payload, signature))
+	assert.False(t, VerifyWebhookSignature([]byte("other"), payload, signature))
+	assert.False(t, VerifyWebhookSignature(secret, []byte(`{"event": "deleted"}`), signature))
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	secret := []byte("secret")
+	payload := []byte(`{"event": "created"}`)
+	retryOpts := RetryOptions{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	newServer := func(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := int(calls.Add(1))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.True(t, VerifyWebhookSignature(secret, body, r.Header.Get(WebhookSignatureHeader)))
+			assert.NotEmpty(t, r.Header.Get(WebhookIDHeader))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+			status := statuses[len(statuses)-1]
+			if call <= len(statuses) {
+				status = statuses[call-1]
+			}
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &calls
+	}
+	newDispatcher := func(t *testing.T, dir string) *WebhookDispatcher {
+		d, err := NewWebhookDispatcher(WebhookDispatcherOptions{
+			Directory:    dir,
+			Secret:       secret,
+			RetryOptions: retryOpts,
+		})
+		require.NoError(t, err)
+		return d
+	}
+
+	t.Run("RequiresDirectoryAndSecret", func(t *testing.T) {
+		_, err := NewWebhookDispatcher(WebhookDispatcherOptions{Secret: secret})
+		assert.Error(t, err)
+		_, err = NewWebhookDispatcher(WebhookDispatcherOptions{Directory: t.TempDir()})
+		assert.Error(t, err)
+	})
+	t.Run("DeliversSignedPayload", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusOK)
+		d := newDispatcher(t, t.TempDir())
+
+		require.NoError(t, d.Send(t.Context(), srv.URL, payload, nil))
+		assert.EqualValues(t, 1, calls.Load())
+
+		pending, err := d.Pending()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+	t.Run("RetriesUntilSuccess", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusServiceUnavailable, http.StatusOK)
+		d := newDispatcher(t, t.TempDir())
+
+		require.NoError(t, d.Send(t.Context(), srv.URL, payload, nil))
+		assert.EqualValues(t, 2, calls.Load())
+	})
+	t.Run("DeadLettersAfterExhaustingAttempts", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusInternalServerError)
+		d := newDispatcher(t, t.TempDir())
+
+		require.Error(t, d.Send(t.Context(), srv.URL, payload, nil))
+		assert.EqualValues(t, retryOpts.MaxAttempts, calls.Load())
+
+		deadLetters, err := d.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, deadLetters, 1)
+		assert.Equal(t, retryOpts.MaxAttempts, deadLetters[0].Attempts)
+		assert.Equal(t, payload, deadLetters[0].Payload)
+		assert.Contains(t, deadLetters[0].LastError, "500")
+
+		pending, err := d.Pending()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+	t.Run("DeadLettersPermanentFailuresWithoutRetrying", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusBadRequest)
+		d := newDispatcher(t, t.TempDir())
+
+		require.Error(t, d.Send(t.Context(), srv.URL, payload, nil))
+		assert.EqualValues(t, 1, calls.Load())
+
+		deadLetters, err := d.DeadLetters()
+		require.NoError(t, err)
+		assert.Len(t, deadLetters, 1)
+	})
+	t.Run("RedeliversDeadLetter", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusBadRequest, http.StatusOK)
+		d := newDispatcher(t, t.TempDir())
+
+		require.Error(t, d.Send(t.Context(), srv.URL, payload, nil))
+		deadLetters, err := d.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, deadLetters, 1)
+
+		require.NoError(t, d.Redeliver(t.Context(), deadLetters[0].ID))
+		assert.EqualValues(t, 2, calls.Load())
+
+		deadLetters, err = d.DeadLetters()
+		require.NoError(t, err)
+		assert.Empty(t, deadLetters)
+	})
+	t.Run("RemovesDeadLetter", func(t *testing.T) {
+		srv, _ := newServer(t, http.StatusBadRequest)
+		d := newDispatcher(t, t.TempDir())
+
+		require.Error(t, d.Send(t.Context(), srv.URL, payload, nil))
+		deadLetters, err := d.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, deadLetters, 1)
+
+		require.NoError(t, d.RemoveDeadLetter(deadLetters[0].ID))
+		deadLetters, err = d.DeadLetters()
+		require.NoError(t, err)
+		assert.Empty(t, deadLetters)
+	})
+	t.Run("RejectsInvalidIDs", func(t *testing.T) {
+		dir := t.TempDir()
+		d := newDispatcher(t, filepath.Join(dir, "webhooks"))
+		outside := filepath.Join(dir, "outside.json")
+		require.NoError(t, os.WriteFile(outside, []byte("{}"), 0644))
+
+		for _, id := range []string{"../../outside", "", "not-hex", strings.Repeat("a", 31)} {
+			assert.Error(t, d.Redeliver(t.Context(), id))
+			assert.Error(t, d.RemoveDeadLetter(id))
+		}
+		assert.FileExists(t, outside)
+	})
+	t.Run("DoesNotResendFinishedDeliveries", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusOK)
+		d := newDispatcher(t, t.TempDir())
+		_, err := d.Enqueue(srv.URL, payload, nil)
+		require.NoError(t, err)
+
+		stale, err := d.Pending()
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		require.NoError(t, d.DeliverPending(t.Context()))
+		assert.EqualValues(t, 1, calls.Load())
+
+		require.NoError(t, d.deliver(t.Context(), &stale[0]))
+		assert.EqualValues(t, 1, calls.Load(), "a stale pending delivery should not be sent again")
+	})
+	t.Run("PendingDeliveriesSurviveRestart", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusOK)
+		dir := t.TempDir()
+
+		d := newDispatcher(t, dir)
+		delivery, err := d.Enqueue(srv.URL, payload, http.Header{"X-Custom": []string{"value"}})
+		require.NoError(t, err)
+		assert.Zero(t, calls.Load())
+
+		restarted := newDispatcher(t, dir)
+		pending, err := restarted.Pending()
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, delivery.ID, pending[0].ID)
+		assert.Equal(t, "value", pending[0].Header.Get("X-Custom"))
+
+		require.NoError(t, restarted.DeliverPending(t.Context()))
+		assert.EqualValues(t, 1, calls.Load())
+
+		pending, err = restarted.Pending()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+	t.Run("AttemptsCarryOverAcrossRestarts", func(t *testing.T) {
+		srv, calls := newServer(t, http.StatusInternalServerError)
+		dir := t.TempDir()
+
+		d := newDispatcher(t, dir)
+		delivery, err := d.Enqueue(srv.URL, payload, nil)
+		require.NoError(t, err)
+		delivery.Attempts = retryOpts.MaxAttempts - 1
+		require.NoError(t, d.writeDelivery(webhookPendingDir, delivery))
+
+		require.Error(t, newDispatcher(t, dir).DeliverPending(t.Context()))
+		assert.EqualValues(t, 1, calls.Load())
+	})
+	t.Run("CanceledContextLeavesDeliveryPending", func(t *testing.T) {
+		srv, _ := newServer(t, http.StatusServiceUnavailable)
+		d := newDispatcher(t, t.TempDir())
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		require.Error(t, d.Send(ctx, srv.URL, payload, nil))
+
+		pending, err := d.Pending()
+		require.NoError(t, err)
+		assert.Len(t, pending, 1)
+		deadLetters, err := d.DeadLetters()
+		require.NoError(t, err)
+		assert.Empty(t, deadLetters)
+	})
+}